@@ -0,0 +1,143 @@
+package cap
+
+import (
+	"fmt"
+	"github.com/gkoh/bom_exporter/bom/schema"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MetricNames is the list of metrics exported by the CAP collector.
+var MetricNames = []string{
+	"bom_cap_alert_area",
+	"bom_cap_alert_expiry_time_seconds",
+	"bom_cap_point_in_area",
+}
+
+// areaCode is the geocode name BoM uses for AMOC area codes.
+const areaCode = "AMOC-AreaCode"
+
+// PointOfInterest is a named location tested against alert polygons.
+type PointOfInterest struct {
+	Name string
+	schema.Point
+}
+
+// ParsePointOfInterest parses a point of interest given as name=lat,lon.
+func ParsePointOfInterest(s string) (PointOfInterest, error) {
+	var p PointOfInterest
+
+	name, coords, ok := strings.Cut(s, "=")
+	lat, lon, ok2 := strings.Cut(coords, ",")
+	if !ok || !ok2 || name == "" {
+		return p, fmt.Errorf("Invalid point of interest '%s', expected name=lat,lon", s)
+	}
+
+	var err error
+	p.Name = name
+	p.Latitude, err = strconv.ParseFloat(strings.TrimSpace(lat), 64)
+	if err != nil {
+		return p, err
+	}
+	p.Longitude, err = strconv.ParseFloat(strings.TrimSpace(lon), 64)
+	return p, err
+}
+
+// CAP combines an unmarshalled CAP alert and the corresponding Prometheus
+// metrics.
+type CAP struct {
+	alert      *schema.Alert
+	points     []PointOfInterest
+	now        func() time.Time
+	areaDesc   *prometheus.Desc
+	expiryDesc *prometheus.Desc
+	pointDesc  *prometheus.Desc
+}
+
+// New creates a CAP collector for an alert retrieved as identifier, testing
+// the given points of interest against any alert polygons.
+func New(identifier string, alert *schema.Alert, points ...PointOfInterest) *CAP {
+	var c CAP
+
+	c.alert = alert
+	c.points = points
+	c.now = time.Now
+
+	labels := prometheus.Labels{"identifier": identifier}
+
+	c.areaDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("bom", "cap", "alert_area"),
+		"Alert covering an area, 1 while active and 0 once cancelled or expired.",
+		[]string{"aac", "area_desc", "event", "severity", "urgency", "certainty", "msg_type"}, labels)
+
+	c.expiryDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("bom", "cap", "alert_expiry_time_seconds"),
+		"Time the alert expires, in seconds since the epoch.",
+		[]string{"aac", "area_desc", "event"}, labels)
+
+	c.pointDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("bom", "cap", "point_in_area"),
+		"Whether a point of interest lies inside the alert area polygon, 1 if inside and 0 otherwise.",
+		[]string{"point", "aac", "area_desc", "event", "severity"}, labels)
+
+	return &c
+}
+
+// active reports whether info is in force.
+func (c *CAP) active(info *schema.Info) bool {
+	return c.alert.MsgType != "Cancel" && info.ActiveAt(c.now())
+}
+
+func (c *CAP) processArea(info *schema.Info, area *schema.AlertArea, ch chan<- prometheus.Metric) {
+	aac := area.Geocode(areaCode)
+	sent := c.alert.Sent
+
+	v := 0.0
+	if c.active(info) {
+		v = 1.0
+	}
+
+	// The value changes once the alert expires, so it is sampled at scrape
+	// time rather than timestamped with the sent time.
+	log.Debugf("%s (%s): %s %s %s %s", area.Description, aac, info.Event, info.Severity, info.Urgency, info.Certainty)
+	ch <- prometheus.MustNewConstMetric(c.areaDesc, prometheus.GaugeValue, v,
+		aac, area.Description, info.Event, info.Severity, info.Urgency, info.Certainty, c.alert.MsgType)
+
+	if info.Expires != nil {
+		ch <- prometheus.NewMetricWithTimestamp(sent,
+			prometheus.MustNewConstMetric(c.expiryDesc, prometheus.GaugeValue, float64(info.Expires.Unix()),
+				aac, area.Description, info.Event))
+	}
+
+	if len(area.Polygons) == 0 {
+		return
+	}
+
+	for _, p := range c.points {
+		inside := 0.0
+		if area.Contains(p.Latitude, p.Longitude) {
+			inside = 1.0
+		}
+		ch <- prometheus.NewMetricWithTimestamp(sent,
+			prometheus.MustNewConstMetric(c.pointDesc, prometheus.GaugeValue, inside,
+				p.Name, aac, area.Description, info.Event, info.Severity))
+	}
+}
+
+// Describe implements the Prometheus Collector interface.
+func (c *CAP) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(c, ch)
+}
+
+// Collect implements the Prometheus Collector interface.
+func (c *CAP) Collect(ch chan<- prometheus.Metric) {
+	for i := range c.alert.Info {
+		info := &c.alert.Info[i]
+		for j := range info.Area {
+			c.processArea(info, &info.Area[j], ch)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package cap
+
+import (
+	"github.com/gkoh/bom_exporter/bom/schema"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCollector(t *testing.T) {
+	data, err := os.ReadFile("../schema/cap.xml")
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %s", err)
+	}
+
+	var a schema.Alert
+	err = a.Parse(data)
+	if err != nil {
+		t.Fatalf("Failed to parse alert: %s", err)
+	}
+
+	points := []PointOfInterest{
+		{Name: "adelaide", Point: schema.Point{Latitude: -34.93, Longitude: 138.6}},
+		{Name: "hahndorf", Point: schema.Point{Latitude: -35.03, Longitude: 138.81}},
+	}
+
+	c := New("IDS21037", &a, points...)
+	c.now = func() time.Time { return time.Date(2022, time.June, 4, 5, 0, 0, 0, time.UTC) }
+
+	expected := `
+# HELP bom_cap_alert_area Alert covering an area, 1 while active and 0 once cancelled or expired.
+# TYPE bom_cap_alert_area gauge
+bom_cap_alert_area{aac="SA_PW001",area_desc="Adelaide Metropolitan",certainty="Likely",event="Severe Weather",identifier="IDS21037",msg_type="Update",severity="Severe",urgency="Expected"} 1
+bom_cap_alert_area{aac="SA_PW003",area_desc="Mid North",certainty="Observed",event="Severe Weather",identifier="IDS21037",msg_type="Update",severity="Minor",urgency="Past"} 0
+bom_cap_alert_area{aac="SA_PW007",area_desc="Mount Lofty Ranges",certainty="Likely",event="Severe Weather",identifier="IDS21037",msg_type="Update",severity="Severe",urgency="Expected"} 1
+# HELP bom_cap_alert_expiry_time_seconds Time the alert expires, in seconds since the epoch.
+# TYPE bom_cap_alert_expiry_time_seconds gauge
+bom_cap_alert_expiry_time_seconds{aac="SA_PW001",area_desc="Adelaide Metropolitan",event="Severe Weather",identifier="IDS21037"} 1.6543392e+09 1654317600000
+bom_cap_alert_expiry_time_seconds{aac="SA_PW003",area_desc="Mid North",event="Severe Weather",identifier="IDS21037"} 1.6543176e+09 1654317600000
+bom_cap_alert_expiry_time_seconds{aac="SA_PW007",area_desc="Mount Lofty Ranges",event="Severe Weather",identifier="IDS21037"} 1.6543392e+09 1654317600000
+# HELP bom_cap_point_in_area Whether a point of interest lies inside the alert area polygon, 1 if inside and 0 otherwise.
+# TYPE bom_cap_point_in_area gauge
+bom_cap_point_in_area{aac="SA_PW001",area_desc="Adelaide Metropolitan",event="Severe Weather",identifier="IDS21037",point="adelaide",severity="Severe"} 1 1654317600000
+bom_cap_point_in_area{aac="SA_PW001",area_desc="Adelaide Metropolitan",event="Severe Weather",identifier="IDS21037",point="hahndorf",severity="Severe"} 0 1654317600000
+bom_cap_point_in_area{aac="SA_PW007",area_desc="Mount Lofty Ranges",event="Severe Weather",identifier="IDS21037",point="adelaide",severity="Severe"} 0 1654317600000
+bom_cap_point_in_area{aac="SA_PW007",area_desc="Mount Lofty Ranges",event="Severe Weather",identifier="IDS21037",point="hahndorf",severity="Severe"} 1 1654317600000
+`
+
+	err = testutil.CollectAndCompare(c, strings.NewReader(expected), MetricNames...)
+	if err != nil {
+		t.Errorf("Unexpected metrics: %s", err)
+	}
+
+	problems, err := testutil.CollectAndLint(c, MetricNames...)
+	if err != nil {
+		t.Errorf("CollectAndLint failed: %v", err)
+	}
+	if len(problems) > 0 {
+		t.Errorf("Problems found: %v", problems)
+	}
+
+	// Cancelling an alert deactivates all of its areas.
+	a.MsgType = "Cancel"
+	for _, info := range a.Info {
+		if c.active(&info) {
+			t.Errorf("Info '%s' active after cancel", info.Headline)
+		}
+	}
+}
+
+func TestParsePointOfInterest(t *testing.T) {
+	p, err := ParsePointOfInterest("home=-34.93,138.6")
+	if err != nil || p.Name != "home" || p.Latitude != -34.93 || p.Longitude != 138.6 {
+		t.Errorf("Got %+v, %v", p, err)
+	}
+
+	for _, s := range []string{"home", "-34.93,138.6", "=1,2", "home=1", "home=a,b"} {
+		_, err := ParsePointOfInterest(s)
+		if err == nil {
+			t.Errorf("Expected error parsing '%s'", s)
+		}
+	}
+}
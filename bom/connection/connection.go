@@ -1,10 +1,15 @@
 package connection
 
+import "io"
+
 // Retriever is the interface that wraps a data connection.
 //
 // Identifier returns the connection identity.
 // Retrieve obtains the data from the underlying connection.
+// Open obtains a reader over the data from the underlying connection, which
+// the caller must close.
 type Retriever interface {
 	Identifier() string
 	Retrieve() ([]byte, error)
+	Open() (io.ReadCloser, error)
 }
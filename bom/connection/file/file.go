@@ -1,9 +1,17 @@
 package file
 
 import (
+	"fmt"
+	"io"
 	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
 )
 
+// validID matches product identifiers that are safe to use as a file name.
+var validID = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
 // Connection holds a filepath based Retriever.
 type Connection struct {
 	filepath string
@@ -14,6 +22,21 @@ func New(id string) *Connection {
 	return &Connection{filepath: id}
 }
 
+// NewInDir creates a Retriever for the product id stored as <dir>/<id>.xml,
+// rejecting identifiers that could resolve outside dir.
+func NewInDir(dir string, id string) (*Connection, error) {
+	if !ValidID(id) {
+		return nil, fmt.Errorf("Invalid product identifier '%s'", id)
+	}
+
+	return New(filepath.Join(dir, id+".xml")), nil
+}
+
+// ValidID reports whether id is a well formed product identifier.
+func ValidID(id string) bool {
+	return validID.MatchString(id)
+}
+
 // Identifier implements the Retriever interface.
 func (c *Connection) Identifier() string {
 	return c.filepath
@@ -23,3 +46,8 @@ func (c *Connection) Identifier() string {
 func (c *Connection) Retrieve() ([]byte, error) {
 	return ioutil.ReadFile(c.filepath)
 }
+
+// Open implements the Retriever interface.
+func (c *Connection) Open() (io.ReadCloser, error) {
+	return os.Open(c.filepath)
+}
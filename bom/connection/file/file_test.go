@@ -1,6 +1,9 @@
 package file
 
 import (
+	"bytes"
+	"io"
+	"path/filepath"
 	"testing"
 )
 
@@ -18,4 +21,39 @@ func TestFileRetriever(t *testing.T) {
 		t.Errorf("Failed to retrieve data: data is nil!")
 	}
 
+	r, err := testC.Open()
+	if err != nil {
+		t.Fatalf("Failed to open data: %s", err)
+	}
+	defer r.Close()
+
+	opened, err := io.ReadAll(r)
+	if err != nil || !bytes.Equal(opened, data) {
+		t.Errorf("Opened data differs from retrieved data: %v", err)
+	}
+}
+
+func TestNewInDir(t *testing.T) {
+	v := []struct {
+		id    string
+		valid bool
+	}{
+		{"IDS60920", true},
+		{"test", true},
+		{"../test", false},
+		{"/etc/passwd", false},
+		{"IDS60920.xml", false},
+		{"", false},
+		{"..", false},
+	}
+
+	for _, k := range v {
+		c, err := NewInDir("..", k.id)
+		if (err == nil) != k.valid {
+			t.Errorf("NewInDir('%s') error %v, expected valid %v", k.id, err, k.valid)
+		}
+		if k.valid && c.Identifier() != filepath.Join("..", k.id+".xml") {
+			t.Errorf("Unexpected path '%s' for '%s'", c.Identifier(), k.id)
+		}
+	}
 }
@@ -1,28 +1,39 @@
 package ftp
 
 import (
-	"bufio"
-	"bytes"
 	"fmt"
 	ftpClient "github.com/gonutz/ftp-client/ftp"
 	log "github.com/sirupsen/logrus"
+	"io"
+	"net"
+	"strconv"
 	"strings"
+	"time"
 )
 
 const ftpBom = "ftp.bom.gov.au"
 const ftpPort = 21
 
+// DefaultAddress is the host:port of the BoM anonymous FTP server.
+const DefaultAddress = ftpBom + ":21"
+
+const productDir = "anon/gen/fwo/"
+
 // Connection holds the details for an FTP based Retriever.
 type Connection struct {
 	id      string
 	address string
 	path    string
-	conn    *ftpClient.Connection
 }
 
 // New implements the Retriever interface.
 func New(id string) *Connection {
-	return &Connection{id: id, address: ftpBom, path: "anon/gen/fwo/" + id + ".xml"}
+	return NewWithAddress(DefaultAddress, id)
+}
+
+// NewWithAddress creates an FTP based Retriever against the given host:port.
+func NewWithAddress(address string, id string) *Connection {
+	return &Connection{id: id, address: address, path: productDir + id + ".xml"}
 }
 
 // Identifier implements the Retriever interface.
@@ -32,39 +43,129 @@ func (c *Connection) Identifier() string {
 
 // Retrieve implements the Retriever interface.
 func (c *Connection) Retrieve() ([]byte, error) {
-	var err error
+	r, err := c.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
 
-	c.conn, err = ftpClient.Connect(ftpBom, ftpPort)
+	return io.ReadAll(r)
+}
+
+// Open implements the Retriever interface. The product is downloaded as it is
+// read, and the FTP connection is closed once it has been read or the reader
+// is closed.
+func (c *Connection) Open() (io.ReadCloser, error) {
+	conn, err := ftpClient.Connect(splitAddress(c.address))
 	if err != nil {
-		log.Errorf("Failed to connect to '%s': %s", ftpBom, err)
+		log.Errorf("Failed to connect to '%s': %s", c.address, err)
 		return nil, err
 	}
-	defer c.conn.Close()
 
-	err = c.conn.Login("anonymous", "")
+	err = conn.Login("anonymous", "")
 	if err != nil {
 		log.Errorf("Failed to login: %s", err)
+		conn.Close()
 		return nil, err
 	}
-	defer c.conn.Quit()
 
 	// check file path
-	_, status, err := c.conn.StatusOf(c.path)
+	_, status, err := conn.StatusOf(c.path)
+	if err == nil && !strings.Contains(status, c.id) {
+		err = fmt.Errorf("Failed to find '%s'", c.id)
+	}
 	if err != nil {
 		log.Errorf("Status failed for '%s'", c.path)
+		conn.Quit()
+		conn.Close()
 		return nil, err
 	}
-	if !strings.Contains(status, c.id) {
-		return nil, fmt.Errorf("Failed to find '%s'", c.id)
+
+	r, w := io.Pipe()
+	go func() {
+		err := conn.Download(c.path, w)
+		if err != nil {
+			log.Errorf("Failed to download '%s': %s", c.path, err)
+		}
+		conn.Quit()
+		conn.Close()
+		w.CloseWithError(err)
+	}()
+
+	return r, nil
+}
+
+// Stage holds the outcome of a single step of an upstream Check.
+type Stage struct {
+	Name    string  `json:"name"`
+	Success bool    `json:"success"`
+	Latency float64 `json:"latency_seconds"`
+	Error   string  `json:"error,omitempty"`
+}
+
+// Check connects, logs in and issues a STAT for the product directory against
+// the FTP server at address, timing each stage. Checking stops at the first
+// failed stage, which is the last entry returned.
+func Check(address string, timeout time.Duration) []Stage {
+	var stages []Stage
+
+	run := func(name string, f func() error) bool {
+		start := time.Now()
+		err := f()
+		s := Stage{Name: name, Success: err == nil, Latency: time.Since(start).Seconds()}
+		if err != nil {
+			s.Error = err.Error()
+		}
+		stages = append(stages, s)
+		return err == nil
+	}
+
+	var conn *ftpClient.Connection
+	ok := run("connect", func() error {
+		c, err := net.DialTimeout("tcp", address, timeout)
+		if err != nil {
+			return err
+		}
+		c.SetDeadline(time.Now().Add(timeout))
+		conn, err = ftpClient.ConnectOn(c)
+		if err != nil {
+			c.Close()
+		}
+		return err
+	})
+	if !ok {
+		return stages
+	}
+	defer conn.Close()
+
+	ok = run("login", func() error {
+		return conn.Login("anonymous", "")
+	})
+	if !ok {
+		return stages
+	}
+	defer conn.Quit()
+
+	run("stat", func() error {
+		_, _, err := conn.StatusOf(productDir)
+		return err
+	})
+
+	return stages
+}
+
+// splitAddress splits host:port into the form expected by the FTP client,
+// falling back to the standard FTP port.
+func splitAddress(address string) (string, uint16) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return address, ftpPort
 	}
 
-	var data bytes.Buffer
-	dataWriter := bufio.NewWriter(&data)
-	err = c.conn.Download(c.path, dataWriter)
+	p, err := strconv.ParseUint(port, 10, 16)
 	if err != nil {
-		log.Errorf("Failed to download '%s': %s", c.path, err)
-		return nil, err
+		return host, ftpPort
 	}
 
-	return data.Bytes(), nil
+	return host, uint16(p)
 }
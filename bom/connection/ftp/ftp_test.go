@@ -1,7 +1,12 @@
 package ftp
 
 import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestFtpConnection(t *testing.T) {
@@ -19,3 +24,91 @@ func TestFtpConnection(t *testing.T) {
 		t.Errorf("Failed to create correct path.")
 	}
 }
+
+// fakeServer answers the FTP control commands used by Check.
+func fakeServer(t *testing.T, loginReply string) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+
+		fmt.Fprintf(c, "220 ready\r\n")
+		s := bufio.NewScanner(c)
+		for s.Scan() {
+			switch strings.Fields(s.Text())[0] {
+			case "USER":
+				fmt.Fprintf(c, "%s\r\n", loginReply)
+			case "STAT":
+				fmt.Fprintf(c, "212-Status of %s\r\n212 End\r\n", productDir)
+			case "QUIT":
+				fmt.Fprintf(c, "221 bye\r\n")
+				return
+			}
+		}
+	}()
+
+	return l.Addr().String()
+}
+
+func TestCheck(t *testing.T) {
+	v := []struct {
+		reply  string
+		stages int
+		ok     bool
+	}{
+		{"230 logged in", 3, true},
+		{"530 not allowed", 2, false},
+	}
+
+	for _, k := range v {
+		stages := Check(fakeServer(t, k.reply), time.Second)
+		if len(stages) != k.stages {
+			t.Fatalf("Got %d stages, expected %d: %+v", len(stages), k.stages, stages)
+		}
+		last := stages[len(stages)-1]
+		if last.Success != k.ok {
+			t.Errorf("Stage '%s' success %v, expected %v: %s", last.Name, last.Success, k.ok, last.Error)
+		}
+	}
+}
+
+func TestCheckUnreachable(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err)
+	}
+	address := l.Addr().String()
+	l.Close()
+
+	stages := Check(address, time.Second)
+	if len(stages) != 1 || stages[0].Name != "connect" || stages[0].Success {
+		t.Errorf("Expected failed connect stage, got %+v", stages)
+	}
+}
+
+func TestSplitAddress(t *testing.T) {
+	v := []struct {
+		address string
+		host    string
+		port    uint16
+	}{
+		{DefaultAddress, "ftp.bom.gov.au", 21},
+		{"localhost:2121", "localhost", 2121},
+		{"localhost", "localhost", 21},
+	}
+
+	for _, k := range v {
+		host, port := splitAddress(k.address)
+		if host != k.host || port != k.port {
+			t.Errorf("Split '%s' into %s %d, expected %s %d", k.address, host, port, k.host, k.port)
+		}
+	}
+}
@@ -15,124 +15,360 @@ var MetricNames = []string{
 	"bom_forecast_air_temperature",
 	"bom_forecast_precipitation_probability",
 	"bom_forecast_icon_code",
+	"bom_forecast_precipitation_amount",
+	"bom_forecast_fire_danger_rating",
+	"bom_forecast_uv_index_max",
+	"bom_forecast_sun_protection_start_time_seconds",
+	"bom_forecast_sun_protection_end_time_seconds",
+	"bom_forecast_text",
+	"bom_forecast_warning_summary",
+	"bom_forecast_period_start_time_seconds",
+	"bom_forecast_period_end_time_seconds",
+	"bom_forecast_period_lead_time_hours",
+}
+
+// BaseUnitMetricNames is the list of metrics exported by the forecast
+// collector when using base units.
+var BaseUnitMetricNames = []string{
+	"bom_forecast_precis",
+	"bom_forecast_air_temperature_celsius",
+	"bom_forecast_precipitation_probability_ratio",
+	"bom_forecast_icon_code",
+	"bom_forecast_precipitation_amount_meters",
+	"bom_forecast_fire_danger_rating",
+	"bom_forecast_uv_index_max",
+	"bom_forecast_sun_protection_start_time_seconds",
+	"bom_forecast_sun_protection_end_time_seconds",
+	"bom_forecast_text",
+	"bom_forecast_warning_summary",
+	"bom_forecast_period_start_time_seconds",
+	"bom_forecast_period_end_time_seconds",
+	"bom_forecast_period_lead_time_seconds",
+}
+
+// LongTextTypes is the list of forecast text types too long to export as a
+// label value. They are exported as a hash instead, see Texts.
+var LongTextTypes = []string{
+	"forecast",
+	"warning_summary",
+	"warning_summary_footer",
+	"product_footer",
 }
 
 // Forecast combines the unmarshalled forecast data and the corresponding
 // Prometheus output metrics.
 type Forecast struct {
-	product            schema.Product
+	product            *schema.Product
+	baseUnits          bool
 	precisDesc         *prometheus.Desc
 	precipitationDesc  *prometheus.Desc
 	airTemperatureDesc *prometheus.Desc
 	iconCodeDesc       *prometheus.Desc
+	amountDesc         *prometheus.Desc
+	fireDangerDesc     *prometheus.Desc
+	uvIndexDesc        *prometheus.Desc
+	sunStartDesc       *prometheus.Desc
+	sunEndDesc         *prometheus.Desc
+	textDesc           *prometheus.Desc
+	warningDesc        *prometheus.Desc
+	startDesc          *prometheus.Desc
+	endDesc            *prometheus.Desc
+	leadTimeDesc       *prometheus.Desc
 }
 
+// areaLabels are the labels common to all forecast metrics.
+var areaLabels = []string{"aac", "parent_aac", "description", "region", "area_type", "index"}
+
 // New creates an exporter instance based on an unmarshalled Product.
-func New(product schema.Product) *Forecast {
+func New(product *schema.Product) *Forecast {
+	return newForecast(product, false)
+}
+
+// NewWithBaseUnits creates an exporter instance which names metrics with
+// their base unit, eg. bom_forecast_air_temperature_celsius, rather than
+// adding a units label.
+func NewWithBaseUnits(product *schema.Product) *Forecast {
+	return newForecast(product, true)
+}
+
+func newForecast(product *schema.Product, baseUnits bool) *Forecast {
 	var f Forecast
 
 	f.product = product
+	f.baseUnits = baseUnits
 
-	labels := prometheus.Labels{"identifier": product.Amoc.Identifier}
+	f.precisDesc = f.desc("precis", "Precis forecast text.", "precis")
 
-	f.precisDesc = prometheus.NewDesc(
-		prometheus.BuildFQName("bom", "forecast", "precis"),
-		"Precis forecast text.",
-		[]string{"aac", "parent_aac", "description", "region", "index", "precis"}, labels)
+	if baseUnits {
+		f.precipitationDesc = f.desc("precipitation_probability_ratio", "Probability of precipitation forecast as a ratio.")
+		f.airTemperatureDesc = f.desc("air_temperature_celsius", "Temperature forecast in Celsius.", "type")
+		f.amountDesc = f.desc("precipitation_amount_meters", "Forecast precipitation amount range in meters.", "bound")
+		f.leadTimeDesc = f.desc("period_lead_time_seconds",
+			"Time from the product issue time to the start of the period, in seconds.", "local_date")
+	} else {
+		f.precipitationDesc = f.desc("precipitation_probability", "Probability of precipitation forecast in percentage.")
+		f.airTemperatureDesc = f.desc("air_temperature", "Temperature forecast in Celsius.", "units", "type")
+		f.amountDesc = f.desc("precipitation_amount", "Forecast precipitation amount range specified in 'units'.", "bound", "units")
+		f.leadTimeDesc = f.desc("period_lead_time_hours",
+			"Time from the product issue time to the start of the period, in hours.", "local_date")
+	}
 
-	f.precipitationDesc = prometheus.NewDesc(
-		prometheus.BuildFQName("bom", "forecast", "precipitation_probability"),
-		"Probability of precipitation forecast in percentage.",
-		[]string{"aac", "parent_aac", "description", "region", "index"}, labels)
+	f.iconCodeDesc = f.desc("icon_code", "Forecast icon code")
+	f.fireDangerDesc = f.desc("fire_danger_rating",
+		"Fire danger rating by fire district, as a level from 0 (no rating) to 4 (catastrophic).", "district")
+	f.uvIndexDesc = f.desc("uv_index_max", "Forecast maximum UV index.", "category")
+	f.sunStartDesc = f.desc("sun_protection_start_time_seconds",
+		"Time sun protection is recommended from, in seconds since the epoch.")
+	f.sunEndDesc = f.desc("sun_protection_end_time_seconds",
+		"Time sun protection is recommended until, in seconds since the epoch.")
+	f.textDesc = f.desc("text",
+		"Value is 1 if present, the hash label identifies the text which can be looked up with Texts.", "type", "hash")
+	f.warningDesc = f.desc("warning_summary",
+		"Warnings summarised for the area, 1 if any are current and 0 otherwise.", "hash")
+	f.startDesc = f.desc("period_start_time_seconds",
+		"Start of the forecast period, in seconds since the epoch.", "local_date")
+	f.endDesc = f.desc("period_end_time_seconds",
+		"End of the forecast period, in seconds since the epoch.", "local_date")
 
-	f.airTemperatureDesc = prometheus.NewDesc(
-		prometheus.BuildFQName("bom", "forecast", "air_temperature"),
-		"Temperature forecast in Celsius.",
-		[]string{"aac", "parent_aac", "description", "region", "index", "units", "type"}, labels)
+	return &f
+}
 
-	f.iconCodeDesc = prometheus.NewDesc(
-		prometheus.BuildFQName("bom", "forecast", "icon_code"),
-		"Forecast icon code",
-		[]string{"aac", "parent_aac", "description", "region", "index"}, labels)
+// desc creates the description of a forecast metric with the common area
+// labels followed by extra.
+func (f *Forecast) desc(name string, help string, extra ...string) *prometheus.Desc {
+	return prometheus.NewDesc(
+		prometheus.BuildFQName("bom", "forecast", name),
+		help,
+		append(append([]string{}, areaLabels...), extra...),
+		prometheus.Labels{"identifier": f.product.Amoc.Identifier})
+}
 
-	return &f
+// values returns the values of the common area labels followed by extra.
+func (f *Forecast) values(area *schema.Area, period *schema.ForecastPeriod, extra ...string) []string {
+	return append([]string{
+		area.Aac,
+		area.ParentAac,
+		area.Description,
+		f.product.Amoc.Source.Region,
+		area.Type,
+		period.Index}, extra...)
+}
+
+// send sends a gauge timestamped with the product issue time.
+func (f *Forecast) send(ch chan<- prometheus.Metric, desc *prometheus.Desc, v float64, values []string) {
+	ch <- prometheus.NewMetricWithTimestamp(time.Time(f.product.Amoc.IssueTimeUTC),
+		prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, v, values...))
 }
 
 var temperatureLabelMap map[string]string = map[string]string{"air_temperature_maximum": "maximum",
 	"air_temperature_minimum": "minimum",
 }
 
+// processTimes sends the validity of a period, labelled with the local date it
+// starts on so that the day offset index can be mapped to a calendar day.
+func (f *Forecast) processTimes(area *schema.Area, period *schema.ForecastPeriod, ch chan<- prometheus.Metric) {
+	start := time.Time(period.StartTimeUTC)
+	end := time.Time(period.EndTimeUTC)
+	if start.IsZero() {
+		return
+	}
+
+	var date string
+	if local := time.Time(period.StartTimeLocal); !local.IsZero() {
+		date = local.Format(time.DateOnly)
+	}
+
+	f.send(ch, f.startDesc, float64(start.Unix()), f.values(area, period, date))
+	if !end.IsZero() {
+		f.send(ch, f.endDesc, float64(end.Unix()), f.values(area, period, date))
+	}
+
+	lead := start.Sub(time.Time(f.product.Amoc.IssueTimeUTC))
+	if f.baseUnits {
+		f.send(ch, f.leadTimeDesc, lead.Seconds(), f.values(area, period, date))
+	} else {
+		f.send(ch, f.leadTimeDesc, lead.Hours(), f.values(area, period, date))
+	}
+}
+
+// textFunc exports a forecast text of a period.
+type textFunc func(f *Forecast, area *schema.Area, period *schema.ForecastPeriod, t *schema.Text, ch chan<- prometheus.Metric)
+
+// textFuncs maps the text types decoded by the collector to the function
+// exporting them, see DecodesText.
+var textFuncs = map[string]textFunc{
+	"precis":                       (*Forecast).processPrecis,
+	"probability_of_precipitation": (*Forecast).processPrecipitation,
+	"fire_danger":                  (*Forecast).processFireDanger,
+	"uv_alert":                     (*Forecast).processUVAlert,
+	"forecast":                     (*Forecast).processText,
+	"warning_summary":              (*Forecast).processWarningSummary,
+	"warning_summary_footer":       (*Forecast).processText,
+	"product_footer":               (*Forecast).processText,
+}
+
+// elementFunc exports a forecast element of a period.
+type elementFunc func(f *Forecast, area *schema.Area, period *schema.ForecastPeriod, e *schema.Element, ch chan<- prometheus.Metric)
+
+// elementFuncs maps the element types decoded by the collector to the
+// function exporting them, see DecodesElement.
+var elementFuncs = map[string]elementFunc{
+	"air_temperature_minimum": (*Forecast).processTemperature,
+	"air_temperature_maximum": (*Forecast).processTemperature,
+	"forecast_icon_code":      (*Forecast).processIconCode,
+	"precipitation_range":     (*Forecast).processPrecipitationRange,
+}
+
+// DecodesText reports whether the collector exports texts of the given type.
+func DecodesText(textType string) bool {
+	_, ok := textFuncs[textType]
+	return ok
+}
+
+// DecodesElement reports whether the collector exports elements of the given
+// type.
+func DecodesElement(elementType string) bool {
+	_, ok := elementFuncs[elementType]
+	return ok
+}
+
 func (f *Forecast) processPeriod(area *schema.Area, period *schema.ForecastPeriod, ch chan<- prometheus.Metric) {
-	region := f.product.Amoc.Source.Region
-	// Process Text entries
-	for _, t := range period.Texts {
-		switch t.Type {
-		case "precis":
-			ch <- prometheus.NewMetricWithTimestamp(time.Time(f.product.Amoc.IssueTimeUTC),
-				prometheus.MustNewConstMetric(
-					f.precisDesc,
-					prometheus.GaugeValue,
-					1.0,
-					area.Aac,
-					area.ParentAac,
-					area.Description,
-					region,
-					period.Index,
-					t.Value))
-
-		case "probability_of_precipitation":
-			v, err := strconv.Atoi(strings.TrimSuffix(t.Value, "%"))
-			if err == nil {
-				ch <- prometheus.NewMetricWithTimestamp(time.Time(f.product.Amoc.IssueTimeUTC),
-					prometheus.MustNewConstMetric(
-						f.precipitationDesc,
-						prometheus.GaugeValue,
-						float64(v),
-						area.Aac,
-						area.ParentAac,
-						area.Description,
-						region,
-						period.Index))
-			}
-		}
+	f.processTimes(area, period, ch)
 
+	for i := range period.Texts {
+		t := &period.Texts[i]
+		if process, ok := textFuncs[t.Type]; ok {
+			process(f, area, period, t, ch)
+		}
 		log.Debugf("%s: %v\n", t.Type, t.Value)
 	}
 
-	// Process Element entries
-	for _, e := range period.Elements {
-		v, err := strconv.ParseFloat(e.Value, 64)
-		if err == nil {
-			switch e.Type {
-			case "air_temperature_minimum", "air_temperature_maximum":
-				ch <- prometheus.NewMetricWithTimestamp(time.Time(f.product.Amoc.IssueTimeUTC),
-					prometheus.MustNewConstMetric(
-						f.airTemperatureDesc,
-						prometheus.GaugeValue,
-						v,
-						area.Aac,
-						area.ParentAac,
-						area.Description,
-						region,
-						period.Index,
-						e.Unit,
-						temperatureLabelMap[e.Type]))
-
-			case "forecast_icon_code":
-				ch <- prometheus.NewMetricWithTimestamp(time.Time(f.product.Amoc.IssueTimeUTC),
-					prometheus.MustNewConstMetric(
-						f.iconCodeDesc,
-						prometheus.GaugeValue,
-						v,
-						area.Aac,
-						area.ParentAac,
-						area.Description,
-						region,
-						period.Index))
+	for i := range period.Elements {
+		e := &period.Elements[i]
+		if process, ok := elementFuncs[e.Type]; ok {
+			process(f, area, period, e, ch)
+		}
+		log.Debugf("%s (%s): %v", e.Type, e.Unit, e.Value)
+	}
+}
+
+func (f *Forecast) processPrecis(area *schema.Area, period *schema.ForecastPeriod, t *schema.Text, ch chan<- prometheus.Metric) {
+	f.send(ch, f.precisDesc, 1.0, f.values(area, period, t.Value))
+}
+
+func (f *Forecast) processPrecipitation(area *schema.Area, period *schema.ForecastPeriod, t *schema.Text, ch chan<- prometheus.Metric) {
+	v, err := strconv.Atoi(strings.TrimSuffix(t.Value, "%"))
+	if err != nil {
+		return
+	}
+	value := float64(v)
+	if f.baseUnits {
+		value = value / 100
+	}
+	f.send(ch, f.precipitationDesc, value, f.values(area, period))
+}
+
+func (f *Forecast) processFireDanger(area *schema.Area, period *schema.ForecastPeriod, t *schema.Text, ch chan<- prometheus.Metric) {
+	for _, line := range t.Lines() {
+		district, level, ok := parseFireDanger(line)
+		if !ok {
+			log.Debugf("Skipping fire danger '%s' at '%s'", line, area.Description)
+			continue
+		}
+		f.send(ch, f.fireDangerDesc, level, f.values(area, period, district))
+	}
+}
+
+func (f *Forecast) processUVAlert(area *schema.Area, period *schema.ForecastPeriod, t *schema.Text, ch chan<- prometheus.Metric) {
+	uv := parseUVAlert(t.Value, time.Time(period.StartTimeLocal))
+	if uv.HasIndex {
+		f.send(ch, f.uvIndexDesc, uv.Index, f.values(area, period, uv.Category))
+	}
+	if !uv.Start.IsZero() && !uv.End.IsZero() {
+		f.send(ch, f.sunStartDesc, float64(uv.Start.Unix()), f.values(area, period))
+		f.send(ch, f.sunEndDesc, float64(uv.End.Unix()), f.values(area, period))
+	}
+}
+
+// processText sends the hash of a long form text, see LongTextTypes.
+func (f *Forecast) processText(area *schema.Area, period *schema.ForecastPeriod, t *schema.Text, ch chan<- prometheus.Metric) {
+	f.send(ch, f.textDesc, 1.0, f.values(area, period, t.Type, TextHash(text(t))))
+}
+
+// processWarningSummary sends the hash of a warning summary and whether it
+// lists any current warnings.
+func (f *Forecast) processWarningSummary(area *schema.Area, period *schema.ForecastPeriod, t *schema.Text, ch chan<- prometheus.Metric) {
+	f.processText(area, period, t, ch)
+
+	value := text(t)
+	v := 0.0
+	if hasWarnings(value) {
+		v = 1.0
+	}
+	f.send(ch, f.warningDesc, v, f.values(area, period, TextHash(value)))
+}
+
+func (f *Forecast) processTemperature(area *schema.Area, period *schema.ForecastPeriod, e *schema.Element, ch chan<- prometheus.Metric) {
+	q, err := e.Quantity()
+	if err != nil {
+		return
+	}
+
+	if f.baseUnits {
+		c, err := q.Convert(schema.UnitCelsius)
+		if err != nil {
+			log.Warnf("Skipping %s at '%s': %s", e.Type, area.Description, err)
+			return
+		}
+		f.send(ch, f.airTemperatureDesc, c.Value, f.values(area, period, temperatureLabelMap[e.Type]))
+		return
+	}
+	f.send(ch, f.airTemperatureDesc, q.Value, f.values(area, period, e.Unit, temperatureLabelMap[e.Type]))
+}
+
+func (f *Forecast) processIconCode(area *schema.Area, period *schema.ForecastPeriod, e *schema.Element, ch chan<- prometheus.Metric) {
+	q, err := e.Quantity()
+	if err != nil {
+		return
+	}
+	f.send(ch, f.iconCodeDesc, q.Value, f.values(area, period))
+}
+
+// processPrecipitationRange sends the lower and upper bounds of a forecast
+// precipitation range, omitting any open bound.
+func (f *Forecast) processPrecipitationRange(area *schema.Area, period *schema.ForecastPeriod, e *schema.Element, ch chan<- prometheus.Metric) {
+	value := e.Value
+	lower, upper, ok := parsePrecipitationRange(value)
+	if !ok {
+		log.Warnf("Skipping precipitation_range '%s' at '%s'", value, area.Description)
+		return
+	}
+	log.Debugf("precipitation_range: %v", value)
+
+	bounds := []struct {
+		name string
+		q    *schema.Quantity
+	}{{"lower", lower}, {"upper", upper}}
+
+	for _, b := range bounds {
+		if b.q == nil {
+			continue
+		}
+
+		extra := []string{b.name}
+		q := *b.q
+		if f.baseUnits {
+			c, err := q.Convert(schema.UnitMeters)
+			if err != nil {
+				log.Warnf("Skipping precipitation_range at '%s': %s", area.Description, err)
+				continue
 			}
+			q = c
+		} else {
+			extra = append(extra, q.Unit.String())
 		}
 
-		log.Debugf("%s (%s): %v", e.Type, e.Unit, e.Value)
+		f.send(ch, f.amountDesc, q.Value, f.values(area, period, extra...))
 	}
 }
 
@@ -143,16 +379,23 @@ func (f *Forecast) Describe(ch chan<- *prometheus.Desc) {
 
 // Collect implements the Prometheus Collector interface.
 func (f *Forecast) Collect(ch chan<- prometheus.Metric) {
-	p := f.product
+	for i := range f.product.Forecast.Area {
+		f.CollectArea(&f.product.Forecast.Area[i], ch)
+	}
+}
 
-	for _, area := range p.Forecast.Area {
-		if area.Type == "location" {
-			log.Debugf("=== %s, %s ===\n", area.Description, p.Amoc.Source.Region)
-			for i, period := range area.Period {
-				log.Debugf("[%d] %v\n", i, period)
-				f.processPeriod(&area, &period, ch)
-			}
-		}
+// CollectArea sends the metrics for a single area to ch. It allows areas to
+// be exported as they are decoded, see schema.Decode. Coastal and marine
+// areas are left to the marine collector.
+func (f *Forecast) CollectArea(area *schema.Area, ch chan<- prometheus.Metric) {
+	if area.Marine() {
+		return
+	}
+
+	log.Debugf("=== %s (%s), %s ===\n", area.Description, area.Type, f.product.Amoc.Source.Region)
+	for i := range area.Period {
+		log.Debugf("[%d] %v\n", i, area.Period[i])
+		f.processPeriod(area, &area.Period[i], ch)
 	}
 }
 
@@ -1,8 +1,12 @@
 package forecast
 
 import (
+	"fmt"
 	"github.com/gkoh/bom_exporter/bom/schema"
 	"github.com/prometheus/client_golang/prometheus/testutil"
+	"os"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 )
@@ -49,7 +53,7 @@ func TestCollector(t *testing.T) {
 			IssueTimeUTC: schema.TimeField(issuetime)},
 		Forecast: &forecast}
 
-	f := New(product)
+	f := New(&product)
 
 	f.Dump()
 
@@ -71,3 +75,319 @@ func TestCollector(t *testing.T) {
 	}
 
 }
+
+func TestBaseUnits(t *testing.T) {
+	period := schema.ForecastPeriod{Index: "0",
+		Elements: []schema.Element{{Type: "air_temperature_maximum", Unit: "Celsius", Value: "21"}},
+		Texts:    []schema.Text{{Type: "probability_of_precipitation", Value: "40%"}}}
+	product := schema.Product{
+		Amoc: schema.Amoc{Identifier: "a5a5a5a5"},
+		Forecast: &schema.Forecast{Area: []schema.Area{
+			{Aac: "bart", Type: "location", Period: []schema.ForecastPeriod{period}},
+		}}}
+
+	f := NewWithBaseUnits(&product)
+
+	expected := `
+# HELP bom_forecast_air_temperature_celsius Temperature forecast in Celsius.
+# TYPE bom_forecast_air_temperature_celsius gauge
+bom_forecast_air_temperature_celsius{aac="bart",area_type="location",description="",identifier="a5a5a5a5",index="0",parent_aac="",region="",type="maximum"} 21 -62135596800000
+# HELP bom_forecast_precipitation_probability_ratio Probability of precipitation forecast as a ratio.
+# TYPE bom_forecast_precipitation_probability_ratio gauge
+bom_forecast_precipitation_probability_ratio{aac="bart",area_type="location",description="",identifier="a5a5a5a5",index="0",parent_aac="",region=""} 0.4 -62135596800000
+`
+
+	err := testutil.CollectAndCompare(f, strings.NewReader(expected), BaseUnitMetricNames...)
+	if err != nil {
+		t.Errorf("Unexpected metrics: %s", err)
+	}
+}
+
+func TestAlerts(t *testing.T) {
+	data, err := os.ReadFile("../schema/IDS10034.xml")
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %s", err)
+	}
+
+	var product schema.Product
+	err = product.Parse(data)
+	if err != nil {
+		t.Fatalf("Failed to parse fixture: %s", err)
+	}
+
+	f := New(&product)
+
+	expected := `
+# HELP bom_forecast_fire_danger_rating Fire danger rating by fire district, as a level from 0 (no rating) to 4 (catastrophic).
+# TYPE bom_forecast_fire_danger_rating gauge
+bom_forecast_fire_danger_rating{aac="SA_ME001",area_type="metropolitan",description="Adelaide",district="Adelaide Metropolitan",identifier="IDS10034",index="1",parent_aac="SA_FA001",region="South Australia"} 2 1648533733000
+bom_forecast_fire_danger_rating{aac="SA_ME001",area_type="metropolitan",description="Adelaide",district="Mount Lofty Ranges",identifier="IDS10034",index="1",parent_aac="SA_FA001",region="South Australia"} 2 1648533733000
+# HELP bom_forecast_sun_protection_end_time_seconds Time sun protection is recommended until, in seconds since the epoch.
+# TYPE bom_forecast_sun_protection_end_time_seconds gauge
+bom_forecast_sun_protection_end_time_seconds{aac="SA_ME001",area_type="metropolitan",description="Adelaide",identifier="IDS10034",index="1",parent_aac="SA_FA001",region="South Australia"} 1.6486194e+09 1648533733000
+# HELP bom_forecast_sun_protection_start_time_seconds Time sun protection is recommended from, in seconds since the epoch.
+# TYPE bom_forecast_sun_protection_start_time_seconds gauge
+bom_forecast_sun_protection_start_time_seconds{aac="SA_ME001",area_type="metropolitan",description="Adelaide",identifier="IDS10034",index="1",parent_aac="SA_FA001",region="South Australia"} 1.6485978e+09 1648533733000
+# HELP bom_forecast_uv_index_max Forecast maximum UV index.
+# TYPE bom_forecast_uv_index_max gauge
+bom_forecast_uv_index_max{aac="SA_ME001",area_type="metropolitan",category="High",description="Adelaide",identifier="IDS10034",index="1",parent_aac="SA_FA001",region="South Australia"} 7 1648533733000
+`
+
+	err = testutil.CollectAndCompare(f, strings.NewReader(expected),
+		"bom_forecast_fire_danger_rating",
+		"bom_forecast_uv_index_max",
+		"bom_forecast_sun_protection_start_time_seconds",
+		"bom_forecast_sun_protection_end_time_seconds")
+	if err != nil {
+		t.Errorf("Unexpected metrics: %s", err)
+	}
+}
+
+func TestParseAlerts(t *testing.T) {
+	ratings := []struct {
+		line     string
+		district string
+		level    float64
+		ok       bool
+	}{
+		{"Mount Lofty Ranges: High", "Mount Lofty Ranges", 2, true},
+		{"Kangaroo Island: Very High", "Kangaroo Island", 2, true},
+		{"West Coast: Severe", "West Coast", 3, true},
+		{"Eastern Eyre Peninsula: Extreme", "Eastern Eyre Peninsula", 3, true},
+		{"Lower Eyre Peninsula: catastrophic", "Lower Eyre Peninsula", 4, true},
+		{"Flinders: No Rating", "Flinders", 0, true},
+		{"Flinders: Unknown", "Flinders", 0, false},
+		{"Total fire ban", "", 0, false},
+	}
+
+	for _, x := range ratings {
+		district, level, ok := parseFireDanger(x.line)
+		if ok != x.ok || (ok && (district != x.district || level != x.level)) {
+			t.Errorf("'%s' parsed as '%s' %v %v, expected '%s' %v %v", x.line, district, level, ok, x.district, x.level, x.ok)
+		}
+	}
+
+	date := time.Date(2022, 3, 30, 0, 0, 0, 0, time.FixedZone("ACDT", 37800))
+	uv := parseUVAlert("Sun protection 10:20am to 4:20pm, UV Index predicted to reach 7 [High]", date)
+	if uv.Start.Format(time.RFC3339) != "2022-03-30T10:20:00+10:30" || uv.End.Format(time.RFC3339) != "2022-03-30T16:20:00+10:30" {
+		t.Errorf("Unexpected sun protection times %s to %s", uv.Start, uv.End)
+	}
+	if !uv.HasIndex || uv.Index != 7 || uv.Category != "High" {
+		t.Errorf("Unexpected UV index %v [%s]", uv.Index, uv.Category)
+	}
+
+	uv = parseUVAlert("UV Index predicted to reach 2 [Low]", date)
+	if !uv.Start.IsZero() || uv.Index != 2 || uv.Category != "Low" {
+		t.Errorf("Unexpected UV alert %+v", uv)
+	}
+}
+
+func TestPrecipitationRange(t *testing.T) {
+	inputs := []struct {
+		value string
+		lower string
+		upper string
+		ok    bool
+	}{
+		{"0 to 0.2 mm", "0", "0.2", true},
+		{"5 to 15 mm", "5", "15", true},
+		{"0 mm", "0", "0", true},
+		{"1 mm", "1", "1", true},
+		{"up to 1 mm", "", "1", true},
+		{"Less than 1 mm", "", "1", true},
+		{"50 mm or more", "50", "", true},
+		{" 10 to 25 mm ", "10", "25", true},
+		{"0 to 5 furlongs", "", "", false},
+		{"some rain", "", "", false},
+	}
+
+	value := func(q *schema.Quantity) string {
+		if q == nil {
+			return ""
+		}
+		return strconv.FormatFloat(q.Value, 'f', -1, 64)
+	}
+
+	for _, x := range inputs {
+		lower, upper, ok := parsePrecipitationRange(x.value)
+		if ok != x.ok || value(lower) != x.lower || value(upper) != x.upper {
+			t.Errorf("'%s' parsed as '%s' to '%s' (%v), expected '%s' to '%s' (%v)",
+				x.value, value(lower), value(upper), ok, x.lower, x.upper, x.ok)
+		}
+	}
+
+	data, err := os.ReadFile("../schema/IDS10044.xml")
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %s", err)
+	}
+
+	var product schema.Product
+	err = product.Parse(data)
+	if err != nil {
+		t.Fatalf("Failed to parse fixture: %s", err)
+	}
+
+	// 10 ranges, each with a lower and upper bound
+	count := testutil.CollectAndCount(New(&product), "bom_forecast_precipitation_amount")
+	if count != 20 {
+		t.Errorf("Got %d metrics, expected %d", count, 20)
+	}
+
+	period := schema.ForecastPeriod{Index: "2",
+		Elements: []schema.Element{{Type: "precipitation_range", Value: "up to 5 mm"}}}
+	product = schema.Product{
+		Amoc: schema.Amoc{Identifier: "a5a5a5a5"},
+		Forecast: &schema.Forecast{Area: []schema.Area{
+			{Aac: "bart", Type: "location", Period: []schema.ForecastPeriod{period}},
+		}}}
+
+	expected := `
+# HELP bom_forecast_precipitation_amount Forecast precipitation amount range specified in 'units'.
+# TYPE bom_forecast_precipitation_amount gauge
+bom_forecast_precipitation_amount{aac="bart",area_type="location",bound="upper",description="",identifier="a5a5a5a5",index="2",parent_aac="",region="",units="mm"} 5 -62135596800000
+`
+	err = testutil.CollectAndCompare(New(&product), strings.NewReader(expected), "bom_forecast_precipitation_amount")
+	if err != nil {
+		t.Errorf("Unexpected metrics: %s", err)
+	}
+
+	expected = `
+# HELP bom_forecast_precipitation_amount_meters Forecast precipitation amount range in meters.
+# TYPE bom_forecast_precipitation_amount_meters gauge
+bom_forecast_precipitation_amount_meters{aac="bart",area_type="location",bound="upper",description="",identifier="a5a5a5a5",index="2",parent_aac="",region=""} 0.005 -62135596800000
+`
+	err = testutil.CollectAndCompare(NewWithBaseUnits(&product), strings.NewReader(expected), "bom_forecast_precipitation_amount_meters")
+	if err != nil {
+		t.Errorf("Unexpected metrics: %s", err)
+	}
+}
+
+func TestTexts(t *testing.T) {
+	data, err := os.ReadFile("../schema/IDS10034.xml")
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %s", err)
+	}
+
+	var product schema.Product
+	err = product.Parse(data)
+	if err != nil {
+		t.Fatalf("Failed to parse fixture: %s", err)
+	}
+
+	// 8 city forecasts, and the region warning summary and product footers
+	count := testutil.CollectAndCount(New(&product), "bom_forecast_text")
+	if count != 10 {
+		t.Errorf("Got %d metrics, expected %d", count, 10)
+	}
+
+	texts := Texts(&product.Forecast.Area[0])
+	if len(texts) != 2 {
+		t.Fatalf("Got %d region texts, expected %d", len(texts), 2)
+	}
+	if texts[0].Type != "warning_summary_footer" || texts[0].AreaType != "region" || !strings.HasPrefix(texts[0].Value, "For latest warnings") {
+		t.Errorf("Unexpected region text %+v", texts[0])
+	}
+	if texts[0].Hash != TextHash(texts[0].Value) || len(texts[0].Hash) != 16 {
+		t.Errorf("Unexpected hash '%s'", texts[0].Hash)
+	}
+
+	summary := "Severe Weather Warning for damaging winds for Mount Lofty Ranges."
+	period := schema.ForecastPeriod{Texts: []schema.Text{
+		{Type: "warning_summary", Value: summary},
+	}}
+	product = schema.Product{
+		Amoc: schema.Amoc{Identifier: "a5a5a5a5"},
+		Forecast: &schema.Forecast{Area: []schema.Area{
+			{Aac: "bart", Type: "region", Period: []schema.ForecastPeriod{period}},
+		}}}
+
+	expected := fmt.Sprintf(`
+# HELP bom_forecast_text Value is 1 if present, the hash label identifies the text which can be looked up with Texts.
+# TYPE bom_forecast_text gauge
+bom_forecast_text{aac="bart",area_type="region",description="",hash="%[1]s",identifier="a5a5a5a5",index="",parent_aac="",region="",type="warning_summary"} 1 -62135596800000
+# HELP bom_forecast_warning_summary Warnings summarised for the area, 1 if any are current and 0 otherwise.
+# TYPE bom_forecast_warning_summary gauge
+bom_forecast_warning_summary{aac="bart",area_type="region",description="",hash="%[1]s",identifier="a5a5a5a5",index="",parent_aac="",region=""} 1 -62135596800000
+`, TextHash(summary))
+
+	err = testutil.CollectAndCompare(New(&product), strings.NewReader(expected), "bom_forecast_text", "bom_forecast_warning_summary")
+	if err != nil {
+		t.Errorf("Unexpected metrics: %s", err)
+	}
+
+	summaries := map[string]bool{
+		summary: true,
+		"Strong Wind Warning for Gulf St Vincent.": true,
+		"Nil.":                           false,
+		"There are no current warnings.": false,
+		"":                               false,
+	}
+	for s, expected := range summaries {
+		if hasWarnings(s) != expected {
+			t.Errorf("'%s' expected %v", s, expected)
+		}
+	}
+}
+
+func TestPeriodTimes(t *testing.T) {
+	parse := func(s string) schema.TimeFieldAttr {
+		v, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t.Fatalf("Invalid time '%s': %s", s, err)
+		}
+		return schema.TimeFieldAttr(v)
+	}
+
+	// The second period spans the end of daylight saving, so is 25 hours.
+	periods := []schema.ForecastPeriod{
+		{Index: "0",
+			StartTimeLocal: parse("2022-03-29T17:00:00+10:30"), EndTimeLocal: parse("2022-03-30T00:00:00+10:30"),
+			StartTimeUTC: parse("2022-03-29T06:30:00Z"), EndTimeUTC: parse("2022-03-29T13:30:00Z")},
+		{Index: "5",
+			StartTimeLocal: parse("2022-04-03T00:00:00+10:30"), EndTimeLocal: parse("2022-04-04T00:00:00+09:30"),
+			StartTimeUTC: parse("2022-04-02T13:30:00Z"), EndTimeUTC: parse("2022-04-03T14:30:00Z")},
+	}
+	product := schema.Product{
+		Amoc: schema.Amoc{Identifier: "IDS10034", IssueTimeUTC: schema.TimeField(time.Time(parse("2022-03-29T06:02:13Z")))},
+		Forecast: &schema.Forecast{Area: []schema.Area{
+			{Aac: "SA_PT001", Description: "Adelaide", Type: "location", Period: periods},
+		}}}
+
+	labels := func(index string, date string) string {
+		return `{aac="SA_PT001",area_type="location",description="Adelaide",identifier="IDS10034",index="` + index + `",local_date="` + date + `",parent_aac="",region=""}`
+	}
+	expected := `
+# HELP bom_forecast_period_end_time_seconds End of the forecast period, in seconds since the epoch.
+# TYPE bom_forecast_period_end_time_seconds gauge
+bom_forecast_period_end_time_seconds` + labels("0", "2022-03-29") + ` 1.6485606e+09 1648533733000
+bom_forecast_period_end_time_seconds` + labels("5", "2022-04-03") + ` 1.6489962e+09 1648533733000
+# HELP bom_forecast_period_lead_time_hours Time from the product issue time to the start of the period, in hours.
+# TYPE bom_forecast_period_lead_time_hours gauge
+bom_forecast_period_lead_time_hours` + labels("0", "2022-03-29") + ` 0.46305555555555555 1648533733000
+bom_forecast_period_lead_time_hours` + labels("5", "2022-04-03") + ` 103.46305555555556 1648533733000
+# HELP bom_forecast_period_start_time_seconds Start of the forecast period, in seconds since the epoch.
+# TYPE bom_forecast_period_start_time_seconds gauge
+bom_forecast_period_start_time_seconds` + labels("0", "2022-03-29") + ` 1.6485354e+09 1648533733000
+bom_forecast_period_start_time_seconds` + labels("5", "2022-04-03") + ` 1.6489062e+09 1648533733000
+`
+
+	err := testutil.CollectAndCompare(New(&product), strings.NewReader(expected),
+		"bom_forecast_period_start_time_seconds",
+		"bom_forecast_period_end_time_seconds",
+		"bom_forecast_period_lead_time_hours")
+	if err != nil {
+		t.Errorf("Unexpected metrics: %s", err)
+	}
+
+	expected = `
+# HELP bom_forecast_period_lead_time_seconds Time from the product issue time to the start of the period, in seconds.
+# TYPE bom_forecast_period_lead_time_seconds gauge
+bom_forecast_period_lead_time_seconds` + labels("0", "2022-03-29") + ` 1667 1648533733000
+bom_forecast_period_lead_time_seconds` + labels("5", "2022-04-03") + ` 372467 1648533733000
+`
+
+	err = testutil.CollectAndCompare(NewWithBaseUnits(&product), strings.NewReader(expected), "bom_forecast_period_lead_time_seconds")
+	if err != nil {
+		t.Errorf("Unexpected metrics: %s", err)
+	}
+}
@@ -0,0 +1,120 @@
+package forecast
+
+import (
+	"github.com/gkoh/bom_exporter/bom/schema"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fireDangerRatings maps fire danger ratings to the levels of the Australian
+// Fire Danger Rating System. Ratings of the former system are mapped to the
+// level which replaced them, eg. Very High to High.
+var fireDangerRatings = map[string]float64{
+	"no rating":    0,
+	"moderate":     1,
+	"low-moderate": 1,
+	"high":         2,
+	"very high":    2,
+	"extreme":      3,
+	"severe":       3,
+	"catastrophic": 4,
+	"code red":     4,
+}
+
+// parseFireDanger parses a fire danger line, eg. "Mount Lofty Ranges: High",
+// into the district and the level of its rating.
+func parseFireDanger(line string) (string, float64, bool) {
+	district, rating, ok := strings.Cut(line, ":")
+	if !ok {
+		return "", 0, false
+	}
+
+	district = strings.TrimSpace(district)
+	level, ok := fireDangerRatings[strings.ToLower(strings.TrimSpace(rating))]
+	return district, level, ok && district != ""
+}
+
+// uvAlert holds the parts of a uv_alert text, eg. "Sun protection 10:20am to
+// 4:20pm, UV Index predicted to reach 7 [High]".
+type uvAlert struct {
+	Start    time.Time
+	End      time.Time
+	Index    float64
+	Category string
+	HasIndex bool
+}
+
+var (
+	sunProtectionPattern = regexp.MustCompile(`(?i)sun protection\s+(\d{1,2}:\d{2}\s*[ap]m)\s+to\s+(\d{1,2}:\d{2}\s*[ap]m)`)
+	uvIndexPattern       = regexp.MustCompile(`(?i)uv index predicted to reach\s+(\d+(?:\.\d+)?)(?:\s*\[([^\]]+)\])?`)
+)
+
+// parseUVAlert parses a uv_alert text. The sun protection times are local
+// clock times on the day, and in the time zone, of date.
+func parseUVAlert(s string, date time.Time) uvAlert {
+	var uv uvAlert
+
+	clock := func(v string) time.Time {
+		t, err := time.Parse("3:04pm", strings.ToLower(strings.ReplaceAll(v, " ", "")))
+		if err != nil {
+			return time.Time{}
+		}
+		return time.Date(date.Year(), date.Month(), date.Day(), t.Hour(), t.Minute(), 0, 0, date.Location())
+	}
+
+	if m := sunProtectionPattern.FindStringSubmatch(s); m != nil && !date.IsZero() {
+		uv.Start = clock(m[1])
+		uv.End = clock(m[2])
+	}
+
+	if m := uvIndexPattern.FindStringSubmatch(s); m != nil {
+		v, err := strconv.ParseFloat(m[1], 64)
+		if err == nil {
+			uv.Index = v
+			uv.Category = m[2]
+			uv.HasIndex = true
+		}
+	}
+
+	return uv
+}
+
+var precipitationRangePattern = regexp.MustCompile(`(?i)^(?:(less than|up to|below)\s+)?(\d+(?:\.\d+)?)(?:\s+to\s+(\d+(?:\.\d+)?))?\s*([a-z]+)(\s+or more)?$`)
+
+// parsePrecipitationRange parses a precipitation_range element value, eg.
+// "0 to 0.2 mm", "5 mm", "up to 1 mm" or "50 mm or more", into its lower and
+// upper bounds. A bound which is open is nil.
+func parsePrecipitationRange(s string) (*schema.Quantity, *schema.Quantity, bool) {
+	m := precipitationRangePattern.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return nil, nil, false
+	}
+
+	unit := schema.ParseUnit(m[4])
+	if unit == schema.UnitUnknown {
+		return nil, nil, false
+	}
+
+	quantity := func(v string) *schema.Quantity {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil
+		}
+		return &schema.Quantity{Value: f, Unit: unit}
+	}
+
+	first := quantity(m[2])
+	switch {
+	case m[1] != "":
+		return nil, first, first != nil
+	case m[5] != "":
+		return first, nil, first != nil
+	case m[3] != "":
+		last := quantity(m[3])
+		return first, last, first != nil && last != nil
+	default:
+		return first, first, first != nil
+	}
+}
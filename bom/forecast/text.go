@@ -0,0 +1,82 @@
+package forecast
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/gkoh/bom_exporter/bom/schema"
+	"slices"
+	"strings"
+)
+
+// Text is a long form forecast text, as identified by the hash label of
+// bom_forecast_text.
+type Text struct {
+	Hash        string `json:"hash"`
+	Aac         string `json:"aac"`
+	Description string `json:"description"`
+	AreaType    string `json:"area_type"`
+	Index       string `json:"index"`
+	Type        string `json:"type"`
+	Value       string `json:"value"`
+}
+
+// TextHash returns the hash label value of a long form text, the first 16 hex
+// digits of its SHA-256 digest.
+func TextHash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Texts returns the long form texts of an area, see LongTextTypes. Coastal
+// and marine areas are left to the marine collector and have none.
+func Texts(area *schema.Area) []Text {
+	var texts []Text
+
+	if area.Marine() {
+		return nil
+	}
+
+	for _, period := range area.Period {
+		for i := range period.Texts {
+			t := &period.Texts[i]
+			if !slices.Contains(LongTextTypes, t.Type) {
+				continue
+			}
+
+			value := text(t)
+			texts = append(texts, Text{
+				Hash:        TextHash(value),
+				Aac:         area.Aac,
+				Description: area.Description,
+				AreaType:    area.Type,
+				Index:       period.Index,
+				Type:        t.Type,
+				Value:       value})
+		}
+	}
+
+	return texts
+}
+
+// text returns the lines of t joined by newlines.
+func text(t *schema.Text) string {
+	return strings.Join(t.Lines(), "\n")
+}
+
+// noWarnings are the prefixes of warning summaries with no current warnings.
+var noWarnings = []string{"nil", "no ", "there are no ", "there are currently no "}
+
+// hasWarnings reports whether a warning summary names any current warnings.
+func hasWarnings(summary string) bool {
+	s := strings.ToLower(strings.TrimSpace(summary))
+	if s == "" {
+		return false
+	}
+
+	for _, prefix := range noWarnings {
+		if strings.HasPrefix(s, prefix) {
+			return false
+		}
+	}
+	return true
+}
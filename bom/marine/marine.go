@@ -0,0 +1,331 @@
+package marine
+
+import (
+	"github.com/gkoh/bom_exporter/bom/quantity"
+	"github.com/gkoh/bom_exporter/bom/schema"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MetricNames is the list of metrics exported by the marine collector.
+var MetricNames = []string{
+	"bom_marine_wind_speed",
+	"bom_marine_sea_height",
+	"bom_marine_swell_height",
+	"bom_marine_warning",
+}
+
+// BaseUnitMetricNames is the list of metrics exported by the marine collector
+// when using base units.
+var BaseUnitMetricNames = []string{
+	"bom_marine_wind_speed_meters_per_second",
+	"bom_marine_sea_height_meters",
+	"bom_marine_swell_height_meters",
+	"bom_marine_warning",
+}
+
+// warningTypes maps the warnings named in warning summaries to label values.
+var warningTypes = []struct {
+	name  string
+	label string
+}{
+	{"strong wind warning", "strong_wind"},
+	{"gale warning", "gale"},
+	{"storm force wind warning", "storm_force_wind"},
+	{"hurricane force wind warning", "hurricane_force_wind"},
+}
+
+// Marine combines the unmarshalled coastal and marine forecast data and the
+// corresponding Prometheus output metrics.
+type Marine struct {
+	product     *schema.Product
+	baseUnits   bool
+	windDesc    quantity.Desc
+	seaDesc     quantity.Desc
+	swellDesc   quantity.Desc
+	warningDesc *prometheus.Desc
+}
+
+// areaLabels are the labels common to all marine metrics.
+var areaLabels = []string{"aac", "parent_aac", "description", "region", "area_type", "index"}
+
+// New creates a marine collector based on an unmarshalled Product.
+func New(product *schema.Product) *Marine {
+	return newMarine(product, false)
+}
+
+// NewWithBaseUnits creates a marine collector which names metrics with their
+// base unit rather than adding a units label.
+func NewWithBaseUnits(product *schema.Product) *Marine {
+	return newMarine(product, true)
+}
+
+func newMarine(product *schema.Product, baseUnits bool) *Marine {
+	var m Marine
+
+	m.product = product
+	m.baseUnits = baseUnits
+
+	m.windDesc = m.quantityDesc("wind_speed", "Forecast wind speed range.", schema.UnitMetersPerSecond, "meters_per_second", "direction", "bound")
+	m.seaDesc = m.quantityDesc("sea_height", "Forecast sea height range.", schema.UnitMeters, "meters", "bound")
+	m.swellDesc = m.quantityDesc("swell_height", "Forecast swell height range.", schema.UnitMeters, "meters", "swell", "direction", "bound")
+
+	m.warningDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("bom", "marine", "warning"),
+		"Marine warning in force for the period, 1 if named in the warning summary and 0 otherwise.",
+		append(append([]string{}, areaLabels...), "warning"),
+		prometheus.Labels{"identifier": product.Amoc.Identifier})
+
+	return &m
+}
+
+// quantityDesc creates the description of a quantity metric, see
+// quantity.NewDesc.
+func (m *Marine) quantityDesc(name string, help string, unit schema.Unit, suffix string, extra ...string) quantity.Desc {
+	return quantity.NewDesc("marine", name, help, unit, suffix, m.baseUnits,
+		append(append([]string{}, areaLabels...), extra...), prometheus.Labels{"identifier": m.product.Amoc.Identifier})
+}
+
+// Range is a lower and upper bound parsed from forecast text, either of which
+// may be missing, eg. "below 1 metre" has only an upper bound.
+type Range struct {
+	Lower *schema.Quantity
+	Upper *schema.Quantity
+}
+
+var rangePattern = regexp.MustCompile(`(?i)\b(below|under|less than|up to|around|about)?\s*(\d+(?:\.\d+)?)(?:\s+to\s+(\d+(?:\.\d+)?))?\s*(knots|metres|metre|meters|meter|m)\b`)
+
+// ParseRange returns the range of knots or metres in forecast text, eg.
+// "15 to 20 knots", "around 1 metre" or "below 1 metre". The lower bound is
+// that of the first range and the upper bound the largest in the text, so
+// "15 to 20 knots, reaching up to 25 knots" is 15 to 25 knots.
+func ParseRange(s string) (Range, bool) {
+	matches := rangePattern.FindAllStringSubmatch(s, -1)
+	if matches == nil {
+		return Range{}, false
+	}
+
+	unit := func(match []string) schema.Unit {
+		if strings.EqualFold(match[4], "knots") {
+			return schema.UnitKnots
+		}
+		return schema.UnitMeters
+	}
+
+	var r Range
+	for i, match := range matches {
+		u := unit(match)
+		if u != unit(matches[0]) {
+			continue
+		}
+
+		value := func(v string) *schema.Quantity {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return nil
+			}
+			return &schema.Quantity{Value: f, Unit: u}
+		}
+
+		var lower, upper *schema.Quantity
+		switch strings.ToLower(match[1]) {
+		case "below", "under", "less than", "up to":
+			upper = value(match[2])
+		default:
+			lower = value(match[2])
+			upper = lower
+			if match[3] != "" {
+				upper = value(match[3])
+			}
+		}
+
+		if i == 0 {
+			r.Lower = lower
+		}
+		if upper != nil && (r.Upper == nil || upper.Value > r.Upper.Value) {
+			r.Upper = upper
+		}
+	}
+
+	return r, true
+}
+
+// compassPoints maps the direction words used in forecast text to compass
+// points.
+var compassPoints = map[string]string{
+	"north":     "N",
+	"northeast": "NE",
+	"east":      "E",
+	"southeast": "SE",
+	"south":     "S",
+	"southwest": "SW",
+	"west":      "W",
+	"northwest": "NW",
+	"variable":  "variable",
+}
+
+// ParseDirection returns the compass point of the first direction word in
+// forecast text, eg. "SW" for "Southwesterly 15 to 20 knots", or "".
+func ParseDirection(s string) string {
+	for _, word := range strings.Fields(strings.ToLower(s)) {
+		word = strings.Trim(word, ".,")
+		word = strings.ReplaceAll(word, "-", "")
+		if p, ok := compassPoints[word]; ok {
+			return p
+		}
+		for _, suffix := range []string{"erly", "ly"} {
+			if p, ok := compassPoints[strings.TrimSuffix(word, suffix)]; ok && strings.HasSuffix(word, suffix) {
+				return p
+			}
+		}
+		if word != "" && word[0] >= '0' && word[0] <= '9' {
+			break
+		}
+	}
+	return ""
+}
+
+func (m *Marine) values(area *schema.Area, period *schema.ForecastPeriod, extra ...string) []string {
+	return append([]string{
+		area.Aac,
+		area.ParentAac,
+		area.Description,
+		m.product.Amoc.Source.Region,
+		area.Type,
+		period.Index}, extra...)
+}
+
+// processRange sends the bounds of a range, converted to the base unit of desc
+// if required.
+func (m *Marine) processRange(desc quantity.Desc, r Range, area *schema.Area, period *schema.ForecastPeriod, ch chan<- prometheus.Metric, extra ...string) {
+	issued := time.Time(m.product.Amoc.IssueTimeUTC)
+
+	bounds := []struct {
+		name string
+		q    *schema.Quantity
+	}{{"lower", r.Lower}, {"upper", r.Upper}}
+
+	for _, b := range bounds {
+		if b.q == nil {
+			continue
+		}
+
+		labels := append(append([]string{}, extra...), b.name)
+		q := *b.q
+		if m.baseUnits {
+			c, err := q.Convert(desc.Unit)
+			if err != nil {
+				log.Warnf("Skipping %s at '%s': %s", desc.Desc, area.Description, err)
+				continue
+			}
+			q = c
+		} else {
+			labels = append(labels, q.Unit.String())
+		}
+
+		ch <- prometheus.NewMetricWithTimestamp(issued,
+			prometheus.MustNewConstMetric(desc.Desc, prometheus.GaugeValue, q.Value, m.values(area, period, labels...)...))
+	}
+}
+
+// textFunc exports a forecast text of a coastal or marine period.
+type textFunc func(m *Marine, area *schema.Area, period *schema.ForecastPeriod, t *schema.Text, ch chan<- prometheus.Metric)
+
+// textFuncs maps the text types decoded by the collector to the function
+// exporting them, see DecodesText. Warning summaries are exported for every
+// period by processWarnings, whether or not the period has one.
+var textFuncs = map[string]textFunc{
+	"forecast_winds":  (*Marine).processWinds,
+	"forecast_seas":   (*Marine).processSeas,
+	"forecast_swell1": (*Marine).processSwell,
+	"forecast_swell2": (*Marine).processSwell,
+	"warning_summary": nil,
+}
+
+// DecodesText reports whether the collector exports texts of the given type.
+func DecodesText(textType string) bool {
+	_, ok := textFuncs[textType]
+	return ok
+}
+
+func (m *Marine) processPeriod(area *schema.Area, period *schema.ForecastPeriod, ch chan<- prometheus.Metric) {
+	for i := range period.Texts {
+		t := &period.Texts[i]
+		log.Debugf("%s: %v", t.Type, t.Value)
+
+		if process := textFuncs[t.Type]; process != nil {
+			process(m, area, period, t, ch)
+		}
+	}
+
+	m.processWarnings(area, period, ch)
+}
+
+func (m *Marine) processWinds(area *schema.Area, period *schema.ForecastPeriod, t *schema.Text, ch chan<- prometheus.Metric) {
+	if r, ok := ParseRange(t.Value); ok {
+		m.processRange(m.windDesc, r, area, period, ch, ParseDirection(t.Value))
+	}
+}
+
+func (m *Marine) processSeas(area *schema.Area, period *schema.ForecastPeriod, t *schema.Text, ch chan<- prometheus.Metric) {
+	if r, ok := ParseRange(t.Value); ok {
+		m.processRange(m.seaDesc, r, area, period, ch)
+	}
+}
+
+func (m *Marine) processSwell(area *schema.Area, period *schema.ForecastPeriod, t *schema.Text, ch chan<- prometheus.Metric) {
+	if r, ok := ParseRange(t.Value); ok {
+		m.processRange(m.swellDesc, r, area, period, ch, strings.TrimPrefix(t.Type, "forecast_swell"), ParseDirection(t.Value))
+	}
+}
+
+// processWarnings sends whether each warning type is named in the warning
+// summaries of a period.
+func (m *Marine) processWarnings(area *schema.Area, period *schema.ForecastPeriod, ch chan<- prometheus.Metric) {
+	issued := time.Time(m.product.Amoc.IssueTimeUTC)
+
+	var warnings string
+	for _, t := range period.Texts {
+		if t.Type == "warning_summary" {
+			warnings += strings.ToLower(t.Value) + "\n"
+		}
+	}
+
+	for _, w := range warningTypes {
+		v := 0.0
+		if strings.Contains(warnings, w.name) {
+			v = 1.0
+		}
+		ch <- prometheus.NewMetricWithTimestamp(issued,
+			prometheus.MustNewConstMetric(m.warningDesc, prometheus.GaugeValue, v, m.values(area, period, w.label)...))
+	}
+}
+
+// Describe implements the Prometheus Collector interface.
+func (m *Marine) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(m, ch)
+}
+
+// Collect implements the Prometheus Collector interface.
+func (m *Marine) Collect(ch chan<- prometheus.Metric) {
+	for i := range m.product.Forecast.Area {
+		m.CollectArea(&m.product.Forecast.Area[i], ch)
+	}
+}
+
+// CollectArea sends the metrics for a single coastal or marine area to ch,
+// ignoring other area types.
+func (m *Marine) CollectArea(area *schema.Area, ch chan<- prometheus.Metric) {
+	if !area.Marine() {
+		return
+	}
+
+	log.Debugf("=== %s, %s ===", area.Description, m.product.Amoc.Source.Region)
+	for i := range area.Period {
+		m.processPeriod(area, &area.Period[i], ch)
+	}
+}
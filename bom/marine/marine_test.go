@@ -0,0 +1,131 @@
+package marine
+
+import (
+	"github.com/gkoh/bom_exporter/bom/schema"
+	"github.com/gkoh/bom_exporter/bom/schema/schematest"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"strings"
+	"testing"
+)
+
+func TestParseRange(t *testing.T) {
+	inputs := []struct {
+		text  string
+		lower float64
+		upper float64
+		unit  schema.Unit
+	}{
+		{"Southwesterly 15 to 20 knots, reaching up to 25 knots in the evening.", 15, 25, schema.UnitKnots},
+		{"1 to 1.5 metres, increasing to 2 to 3 metres offshore.", 1, 3, schema.UnitMeters},
+		{"20 to 25 knots, easing to 10 knots.", 20, 25, schema.UnitKnots},
+		{"1 to 1.5 metres.", 1, 1.5, schema.UnitMeters},
+		{"Southwesterly around 1 metre.", 1, 1, schema.UnitMeters},
+		{"Below 1 metre.", -1, 1, schema.UnitMeters},
+		{"Southerly 2 m.", 2, 2, schema.UnitMeters},
+	}
+
+	for _, x := range inputs {
+		r, ok := ParseRange(x.text)
+		if !ok {
+			t.Errorf("Failed to parse '%s'", x.text)
+			continue
+		}
+		if x.lower < 0 {
+			if r.Lower != nil {
+				t.Errorf("'%s': unexpected lower bound %+v", x.text, *r.Lower)
+			}
+		} else if r.Lower == nil || r.Lower.Value != x.lower || r.Lower.Unit != x.unit {
+			t.Errorf("'%s': got lower bound %+v, expected %f", x.text, r.Lower, x.lower)
+		}
+		if r.Upper == nil || r.Upper.Value != x.upper || r.Upper.Unit != x.unit {
+			t.Errorf("'%s': got upper bound %+v, expected %f", x.text, r.Upper, x.upper)
+		}
+	}
+
+	if _, ok := ParseRange("Cloudy. 60% chance of showers."); ok {
+		t.Errorf("Unexpected range in text without units")
+	}
+}
+
+func TestParseDirection(t *testing.T) {
+	inputs := map[string]string{
+		"Southwesterly 15 to 20 knots":         "SW",
+		"West to southwesterly 10 to 15 knots": "W",
+		"North-easterly 10 knots":              "NE",
+		"Variable about 5 knots":               "variable",
+		"Southerly 1 metre.":                   "S",
+		"1 to 1.5 metres.":                     "",
+		"15 knots, tending westerly":           "",
+	}
+
+	for text, expected := range inputs {
+		if d := ParseDirection(text); d != expected {
+			t.Errorf("'%s': got direction '%s', expected '%s'", text, d, expected)
+		}
+	}
+}
+
+func TestCollector(t *testing.T) {
+	p := schematest.ParseFile(t, "../schema/coastal.xml")
+
+	m := New(p)
+
+	expected := `
+# HELP bom_marine_sea_height Forecast sea height range.
+# TYPE bom_marine_sea_height gauge
+bom_marine_sea_height{aac="SA_MW003",area_type="coast",bound="lower",description="South Central Gulfs Waters",identifier="IDS00001",index="0",parent_aac="SA_FA001",region="South Australia",units="m"} 2.5 1654317600000
+bom_marine_sea_height{aac="SA_MW003",area_type="coast",bound="upper",description="South Central Gulfs Waters",identifier="IDS00001",index="0",parent_aac="SA_FA001",region="South Australia",units="m"} 3.5 1654317600000
+bom_marine_sea_height{aac="SA_MW005",area_type="coast",bound="lower",description="Gulf St Vincent",identifier="IDS00001",index="0",parent_aac="SA_FA001",region="South Australia",units="m"} 1 1654317600000
+bom_marine_sea_height{aac="SA_MW005",area_type="coast",bound="upper",description="Gulf St Vincent",identifier="IDS00001",index="0",parent_aac="SA_FA001",region="South Australia",units="m"} 1.5 1654317600000
+bom_marine_sea_height{aac="SA_MW005",area_type="coast",bound="upper",description="Gulf St Vincent",identifier="IDS00001",index="1",parent_aac="SA_FA001",region="South Australia",units="m"} 1 1654317600000
+`
+
+	err := testutil.CollectAndCompare(m, strings.NewReader(expected), "bom_marine_sea_height")
+	if err != nil {
+		t.Errorf("Unexpected metrics: %s", err)
+	}
+
+	// 3 periods in coastal areas: wind, sea and warnings in each, plus swell
+	// where given. Each range has a lower and upper bound.
+	count := testutil.CollectAndCount(m, "bom_marine_wind_speed")
+	if count != 6 {
+		t.Errorf("Got %d wind speed metrics, expected 6", count)
+	}
+	count = testutil.CollectAndCount(m, "bom_marine_sea_height")
+	if count != 5 {
+		t.Errorf("Got %d sea height metrics, expected 5", count)
+	}
+	count = testutil.CollectAndCount(m, "bom_marine_swell_height")
+	if count != 6 {
+		t.Errorf("Got %d swell height metrics, expected 6", count)
+	}
+	count = testutil.CollectAndCount(m, "bom_marine_warning")
+	if count != 3*len(warningTypes) {
+		t.Errorf("Got %d warning metrics, expected %d", count, 3*len(warningTypes))
+	}
+
+	problems, err := testutil.CollectAndLint(m, MetricNames...)
+	if err != nil {
+		t.Errorf("CollectAndLint failed: %v", err)
+	}
+	if len(problems) > 0 {
+		t.Errorf("Problems found: %v", problems)
+	}
+
+	b := NewWithBaseUnits(p)
+	expected = `
+# HELP bom_marine_wind_speed_meters_per_second Forecast wind speed range.
+# TYPE bom_marine_wind_speed_meters_per_second gauge
+bom_marine_wind_speed_meters_per_second{aac="SA_MW003",area_type="coast",bound="lower",description="South Central Gulfs Waters",direction="W",identifier="IDS00001",index="0",parent_aac="SA_FA001",region="South Australia"} 12.861111111111112 1654317600000
+bom_marine_wind_speed_meters_per_second{aac="SA_MW003",area_type="coast",bound="upper",description="South Central Gulfs Waters",direction="W",identifier="IDS00001",index="0",parent_aac="SA_FA001",region="South Australia"} 16.976666666666667 1654317600000
+bom_marine_wind_speed_meters_per_second{aac="SA_MW005",area_type="coast",bound="lower",description="Gulf St Vincent",direction="SW",identifier="IDS00001",index="0",parent_aac="SA_FA001",region="South Australia"} 7.716666666666667 1654317600000
+bom_marine_wind_speed_meters_per_second{aac="SA_MW005",area_type="coast",bound="lower",description="Gulf St Vincent",direction="W",identifier="IDS00001",index="1",parent_aac="SA_FA001",region="South Australia"} 5.144444444444445 1654317600000
+bom_marine_wind_speed_meters_per_second{aac="SA_MW005",area_type="coast",bound="upper",description="Gulf St Vincent",direction="SW",identifier="IDS00001",index="0",parent_aac="SA_FA001",region="South Australia"} 12.861111111111112 1654317600000
+bom_marine_wind_speed_meters_per_second{aac="SA_MW005",area_type="coast",bound="upper",description="Gulf St Vincent",direction="W",identifier="IDS00001",index="1",parent_aac="SA_FA001",region="South Australia"} 7.716666666666667 1654317600000
+`
+
+	err = testutil.CollectAndCompare(b, strings.NewReader(expected), "bom_marine_wind_speed_meters_per_second")
+	if err != nil {
+		t.Errorf("Unexpected metrics: %s", err)
+	}
+}
@@ -1,12 +1,17 @@
 package bom
 
 import (
+	"github.com/gkoh/bom_exporter/bom/cap"
 	"github.com/gkoh/bom_exporter/bom/connection"
 	"github.com/gkoh/bom_exporter/bom/forecast"
+	"github.com/gkoh/bom_exporter/bom/marine"
 	"github.com/gkoh/bom_exporter/bom/observations"
 	"github.com/gkoh/bom_exporter/bom/schema"
+	"github.com/gkoh/bom_exporter/bom/tides"
+	"github.com/gkoh/bom_exporter/bom/warnings"
 	"github.com/prometheus/client_golang/prometheus"
 	log "github.com/sirupsen/logrus"
+	"io"
 	"sync"
 )
 
@@ -15,28 +20,150 @@ type Metric struct {
 	sync.Mutex
 	identifier string
 	conn       connection.Retriever
+	mode       schema.Mode
 	product    schema.Product
+	stream     bool
+	reader     io.ReadCloser
+	decoder    *schema.Decoder
+	texts      []forecast.Text
+	baseUnits  bool
+	alert      *schema.Alert
+	points     []cap.PointOfInterest
+	tideHeight bool
 }
 
 // New creates a new Metric with the given retriever.
 func New(retriever connection.Retriever) *Metric {
-	return &Metric{identifier: retriever.Identifier(), conn: retriever}
+	return NewWithMode(retriever, schema.Lenient)
+}
+
+// NewWithMode creates a new Metric with the given retriever, parsing products
+// with the given schema validation mode.
+func NewWithMode(retriever connection.Retriever, mode schema.Mode) *Metric {
+	return &Metric{identifier: retriever.Identifier(), conn: retriever, mode: mode}
+}
+
+// NewStreaming creates a new Metric with the given retriever which never
+// holds the whole product in memory. RetrieveAndParse opens the product and
+// reads only its header, and the next Collect decodes the rest as it is read,
+// collecting each area or station in turn. Later Collects open the product
+// again. As stations cannot be looked ahead at for several levels,
+// observations always carry the level label. Products are parsed leniently.
+func NewStreaming(retriever connection.Retriever) *Metric {
+	return NewStreamingWithMode(retriever, schema.Lenient)
+}
+
+// NewStreamingWithMode creates a new streaming Metric, see NewStreaming, with
+// the given schema validation mode. In Strict mode problems in the header are
+// returned by RetrieveAndParse, but a product without areas or stations is
+// only found once it has been collected, and is reported as an invalid
+// metric.
+func NewStreamingWithMode(retriever connection.Retriever, mode schema.Mode) *Metric {
+	return &Metric{identifier: retriever.Identifier(), conn: retriever, mode: mode, stream: true}
+}
+
+// WithBaseUnits switches m to export each quantity once, converted to its
+// base unit which is named in the metric name, rather than with a units
+// label. It returns m.
+func (m *Metric) WithBaseUnits() *Metric {
+	m.baseUnits = true
+	return m
+}
+
+// WithPointsOfInterest sets the points tested against the polygons of CAP
+// alerts. It returns m.
+func (m *Metric) WithPointsOfInterest(points ...cap.PointOfInterest) *Metric {
+	m.points = points
+	return m
+}
+
+// WithTideInterpolation switches m to also export the tide height at scrape
+// time, interpolated between the predictions of tide products. It returns m.
+func (m *Metric) WithTideInterpolation() *Metric {
+	m.tideHeight = true
+	return m
 }
 
 // RetrieveAndParse gathers the data and parses it into the local
-// representation.
+// representation. Both BoM products and CAP alerts are accepted.
 func (m *Metric) RetrieveAndParse() error {
+	if m.stream {
+		m.Lock()
+		defer m.Unlock()
+		return m.open()
+	}
+
 	data, err := m.conn.Retrieve()
 	if err != nil {
 		log.Warnf("Failed to retrieve: %s", err)
 		return err
 	}
 
-	return m.product.Parse(data)
+	root, err := schema.RootElement(data)
+	if err != nil {
+		return err
+	}
+	if root == "alert" {
+		m.alert = &schema.Alert{}
+		return m.alert.Parse(data)
+	}
+
+	return m.product.ParseMode(data, m.mode)
+}
+
+// open opens the product stream and reads its header, leaving the rest to be
+// decoded by the next Collect. A CAP alert is read in full and kept.
+func (m *Metric) open() error {
+	m.close()
+
+	r, err := m.conn.Open()
+	if err != nil {
+		log.Warnf("Failed to retrieve: %s", err)
+		return err
+	}
+
+	d := schema.NewDecoder(r, m.mode)
+	_, err = d.Header()
+	if err != nil || d.Alert() != nil {
+		r.Close()
+		m.alert = d.Alert()
+		return err
+	}
+
+	m.alert = nil
+	m.reader = r
+	m.decoder = d
+	return nil
 }
 
-// Describe implements the Collector interface.
+// close closes any product stream not yet collected.
+func (m *Metric) close() error {
+	if m.reader == nil {
+		return nil
+	}
+
+	err := m.reader.Close()
+	m.reader = nil
+	m.decoder = nil
+	return err
+}
+
+// Close releases the product stream opened by RetrieveAndParse for a
+// streaming Metric if it has not been collected. A later Collect opens the
+// product again.
+func (m *Metric) Close() error {
+	m.Lock()
+	defer m.Unlock()
+
+	return m.close()
+}
+
+// Describe implements the Collector interface. A streaming Metric is an
+// unchecked collector, as describing it would consume the product stream.
 func (m *Metric) Describe(ch chan<- *prometheus.Desc) {
+	if m.stream {
+		return
+	}
 	prometheus.DescribeByCollect(m, ch)
 }
 
@@ -45,11 +172,165 @@ func (m *Metric) Collect(ch chan<- prometheus.Metric) {
 	m.Lock()
 	defer m.Unlock()
 
-	if m.product.Forecast != nil {
-		f := forecast.New(m.product)
-		f.Collect(ch)
+	if m.stream && m.decoder == nil && m.alert == nil {
+		err := m.open()
+		if err != nil {
+			ch <- prometheus.NewInvalidMetric(prometheus.NewInvalidDesc(err), err)
+			return
+		}
+	}
+
+	if m.alert != nil {
+		cap.New(m.identifier, m.alert, m.points...).Collect(ch)
+	} else if m.stream {
+		h := streamHandler{ch: ch, baseUnits: m.baseUnits, tideHeight: m.tideHeight}
+		err := m.decoder.Decode(&h)
+		m.close()
+		if err != nil {
+			log.Warnf("Failed to decode '%s': %s", m.identifier, err)
+			ch <- prometheus.NewInvalidMetric(prometheus.NewInvalidDesc(err), err)
+			return
+		}
+		m.texts = h.texts
+	} else if m.product.Forecast != nil {
+		newForecast(&m.product, m.baseUnits).Collect(ch)
+		newMarine(&m.product, m.baseUnits).Collect(ch)
 	} else if m.product.Observations != nil {
-		o := observations.New(m.product)
-		o.Collect(ch)
+		newObservations(&m.product, m.baseUnits).Collect(ch)
+	} else if m.product.Warning != nil {
+		warnings.New(&m.product).Collect(ch)
+	} else if m.product.Tides != nil {
+		newTides(&m.product, m.tideHeight).Collect(ch)
 	}
 }
+
+// Texts returns the long form forecast texts of the product, as identified by
+// the hash label of bom_forecast_text. A streaming Metric returns the texts of
+// the product last collected, decoding the product opened by RetrieveAndParse
+// if it has not been collected yet.
+func (m *Metric) Texts() []forecast.Text {
+	m.Lock()
+	defer m.Unlock()
+
+	if m.stream {
+		if m.decoder != nil {
+			var h textHandler
+			err := m.decoder.Decode(&h)
+			m.close()
+			if err != nil {
+				log.Warnf("Failed to decode '%s': %s", m.identifier, err)
+				return nil
+			}
+			m.texts = h.texts
+		}
+		return m.texts
+	}
+
+	if m.product.Forecast == nil {
+		return nil
+	}
+
+	var texts []forecast.Text
+	for i := range m.product.Forecast.Area {
+		texts = append(texts, forecast.Texts(&m.product.Forecast.Area[i])...)
+	}
+	return texts
+}
+
+// newForecast creates the forecast collector in the selected naming scheme.
+func newForecast(p *schema.Product, baseUnits bool) *forecast.Forecast {
+	if baseUnits {
+		return forecast.NewWithBaseUnits(p)
+	}
+	return forecast.New(p)
+}
+
+// newMarine creates the marine forecast collector in the selected naming
+// scheme.
+func newMarine(p *schema.Product, baseUnits bool) *marine.Marine {
+	if baseUnits {
+		return marine.NewWithBaseUnits(p)
+	}
+	return marine.New(p)
+}
+
+// newObservations creates the observations collector in the selected naming
+// scheme.
+func newObservations(p *schema.Product, baseUnits bool) *observations.Observations {
+	if baseUnits {
+		return observations.NewWithBaseUnits(p)
+	}
+	return observations.New(p)
+}
+
+// newTides creates the tides collector, optionally interpolating the height.
+func newTides(p *schema.Product, interpolate bool) *tides.Tides {
+	if interpolate {
+		return tides.NewWithInterpolation(p)
+	}
+	return tides.New(p)
+}
+
+// streamHandler passes each decoded area or station to the collector for the
+// product type, gathering the long form forecast texts as it goes.
+type streamHandler struct {
+	ch           chan<- prometheus.Metric
+	baseUnits    bool
+	tideHeight   bool
+	texts        []forecast.Text
+	forecast     *forecast.Forecast
+	marine       *marine.Marine
+	observations *observations.Observations
+}
+
+func (h *streamHandler) Header(p *schema.Product) error {
+	if p.Forecast != nil {
+		h.forecast = newForecast(p, h.baseUnits)
+		h.marine = newMarine(p, h.baseUnits)
+	} else if p.Observations != nil {
+		h.observations = newObservations(p, h.baseUnits).WithLevels()
+	} else if p.Warning != nil {
+		warnings.New(p).Collect(h.ch)
+	} else if p.Tides != nil {
+		newTides(p, h.tideHeight).Collect(h.ch)
+	}
+	return nil
+}
+
+func (h *streamHandler) Area(a *schema.Area) error {
+	if h.forecast != nil {
+		h.forecast.CollectArea(a, h.ch)
+		h.marine.CollectArea(a, h.ch)
+		h.texts = append(h.texts, forecast.Texts(a)...)
+	}
+	return nil
+}
+
+func (h *streamHandler) Station(s *schema.Station) error {
+	if h.observations != nil {
+		h.observations.CollectStation(s, h.ch)
+	}
+	return nil
+}
+
+// textHandler gathers the long form forecast texts of each decoded area.
+type textHandler struct {
+	texts []forecast.Text
+}
+
+func (h *textHandler) Header(p *schema.Product) error { return nil }
+
+func (h *textHandler) Area(a *schema.Area) error {
+	h.texts = append(h.texts, forecast.Texts(a)...)
+	return nil
+}
+
+func (h *textHandler) Station(s *schema.Station) error { return nil }
+
+// Stream decodes the product read from r, sending the metrics for each area or
+// station to ch as soon as it is decoded. Only a single area or station is
+// held in memory at a time. As it cannot look ahead for stations with several
+// levels, observations always carry the level label.
+func Stream(r io.Reader, ch chan<- prometheus.Metric) error {
+	return schema.Decode(r, &streamHandler{ch: ch})
+}
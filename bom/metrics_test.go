@@ -1,8 +1,19 @@
 package bom
 
 import (
+	"errors"
+	"github.com/gkoh/bom_exporter/bom/cap"
+	"github.com/gkoh/bom_exporter/bom/connection"
 	"github.com/gkoh/bom_exporter/bom/connection/file"
+	"github.com/gkoh/bom_exporter/bom/schema"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/expfmt"
+	log "github.com/sirupsen/logrus"
+	"os"
+	"reflect"
+	"runtime"
+	"strings"
 	"testing"
 )
 
@@ -76,3 +87,308 @@ func TestObservationsCollector(t *testing.T) {
 		t.Errorf("%v", problems)
 	}
 }
+
+// gatherText returns the text exposition of the metrics collected from c.
+func gatherText(t testing.TB, c prometheus.Collector) string {
+	registry := prometheus.NewPedanticRegistry()
+	registry.MustRegister(c)
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Failed to gather: %v", err)
+	}
+
+	var b strings.Builder
+	for _, mf := range families {
+		expfmt.MetricFamilyToText(&b, mf)
+	}
+	return b.String()
+}
+
+func TestStreaming(t *testing.T) {
+	for _, id := range []string{"IDS10034", "IDS10044", "IDS60920", "IDT60920", "IDS21037", "tides", "coastal"} {
+		path := "schema/" + id + ".xml"
+
+		m := New(file.New(path))
+		err := m.RetrieveAndParse()
+		if err != nil {
+			t.Fatalf("Failed to retrieve and parse '%s': %v", path, err)
+		}
+
+		s := NewStreaming(file.New(path))
+		err = s.RetrieveAndParse()
+		if err != nil {
+			t.Fatalf("Failed to retrieve and stream '%s': %v", path, err)
+		}
+
+		expected := gatherText(t, withLevels(m))
+		got := gatherText(t, s)
+		if got != expected {
+			t.Errorf("%s: streamed metrics differ from parsed metrics", id)
+		}
+
+		// Collecting again opens the product again.
+		if got := gatherText(t, s); got != expected {
+			t.Errorf("%s: streamed metrics differ when collected again", id)
+		}
+
+		if !reflect.DeepEqual(s.Texts(), m.Texts()) {
+			t.Errorf("%s: streamed texts differ from parsed texts", id)
+		}
+	}
+
+	s := NewStreaming(file.New("schema/missing.xml"))
+	if err := s.RetrieveAndParse(); err == nil {
+		t.Errorf("Expected error retrieving missing product")
+	}
+}
+
+// withLevels returns the collector for the parsed observations of m with the
+// level label always carried, as streamed observations do, or m itself for
+// other products.
+func withLevels(m *Metric) prometheus.Collector {
+	if m.product.Observations == nil {
+		return m
+	}
+	return newObservations(&m.product, m.baseUnits).WithLevels()
+}
+
+func TestStreamingTexts(t *testing.T) {
+	path := "schema/IDS10034.xml"
+
+	m := New(file.New(path))
+	err := m.RetrieveAndParse()
+	if err != nil {
+		t.Fatalf("Failed to retrieve and parse '%s': %v", path, err)
+	}
+
+	// Texts are decoded from the opened product if it has not been
+	// collected, which then opens it again.
+	s := NewStreaming(file.New(path))
+	err = s.RetrieveAndParse()
+	if err != nil {
+		t.Fatalf("Failed to retrieve and stream '%s': %v", path, err)
+	}
+	if len(s.Texts()) == 0 || !reflect.DeepEqual(s.Texts(), m.Texts()) {
+		t.Errorf("Streamed texts differ from parsed texts")
+	}
+	if gatherText(t, s) != gatherText(t, m) {
+		t.Errorf("Streamed metrics differ from parsed metrics")
+	}
+}
+
+func TestStreamingStrict(t *testing.T) {
+	dir := t.TempDir()
+	header := `<?xml version="1.0"?>
+<product version="v1.7.1">
+  <amoc>
+    <source><sender>Australian Government Bureau of Meteorology</sender><region>South Australia</region></source>
+    <identifier>IDX00000</identifier><issue-time-utc>2022-05-22T04:41:01+00:00</issue-time-utc>
+    <sent-time>2022-05-22T04:41:01+00:00</sent-time><status>O</status><service>WSP</service>
+    <product-type>O</product-type><phase>NEW</phase>
+  </amoc>
+`
+	v := []struct {
+		name    string
+		product string
+		parse   bool
+		collect bool
+	}{
+		{"valid", header + `<observations><station wmo-id="1" bom-id="1" stn-name="A"/></observations></product>`, true, true},
+		{"version", strings.Replace(header, "v1.7.1", "v9.9", 1) + `<observations><station wmo-id="1" bom-id="1" stn-name="A"/></observations></product>`, false, false},
+		{"empty", header + `<observations></observations></product>`, true, false},
+		{"content", header + `</product>`, false, false},
+	}
+
+	for _, k := range v {
+		path := dir + "/" + k.name + ".xml"
+		err := os.WriteFile(path, []byte(k.product), 0644)
+		if err != nil {
+			t.Fatalf("Failed to write file: %s", err)
+		}
+
+		s := NewStreamingWithMode(file.New(path), schema.Strict)
+		err = s.RetrieveAndParse()
+		var ve *schema.ValidationError
+		if (err == nil) != k.parse || (err != nil && !errors.As(err, &ve)) {
+			t.Errorf("%s: unexpected error: %v", k.name, err)
+		}
+		if err != nil {
+			continue
+		}
+
+		registry := prometheus.NewPedanticRegistry()
+		registry.MustRegister(s)
+		_, err = registry.Gather()
+		if (err == nil) != k.collect {
+			t.Errorf("%s: unexpected error collecting: %v", k.name, err)
+		}
+	}
+}
+
+func TestStreamingLevels(t *testing.T) {
+	path := t.TempDir() + "/levels.xml"
+	err := os.WriteFile(path, []byte(`<?xml version="1.0"?>
+<product version="v1.7.1">
+  <amoc><identifier>IDX00000</identifier><issue-time-utc>2022-05-22T04:41:01+00:00</issue-time-utc></amoc>
+  <observations>
+    <station wmo-id="1" bom-id="1" stn-name="SURFACE">
+      <period index="0" time-utc="2022-05-22T04:40:00+00:00">
+        <level index="0" type="surface"><element units="Celsius" type="air_temperature">20.9</element></level>
+      </period>
+    </station>
+    <station wmo-id="2" bom-id="2" stn-name="DEPTHS">
+      <period index="0" time-utc="2022-05-22T04:40:00+00:00">
+        <level index="0" type="surface"><element units="Celsius" type="air_temperature">18.1</element></level>
+        <level index="1" type="depth"><element units="Celsius" type="air_temperature">16.2</element></level>
+      </period>
+    </station>
+  </observations>
+</product>
+`), 0644)
+	if err != nil {
+		t.Fatalf("Failed to write file: %s", err)
+	}
+
+	m := New(file.New(path))
+	err = m.RetrieveAndParse()
+	if err != nil {
+		t.Fatalf("Failed to retrieve and parse '%s': %v", path, err)
+	}
+
+	s := NewStreaming(file.New(path))
+	err = s.RetrieveAndParse()
+	if err != nil {
+		t.Fatalf("Failed to retrieve and stream '%s': %v", path, err)
+	}
+
+	expected := gatherText(t, m)
+	if !strings.Contains(expected, `level="1"`) {
+		t.Errorf("Expected a level label:\n%s", expected)
+	}
+	if got := gatherText(t, s); got != expected {
+		t.Errorf("Streamed metrics differ from parsed metrics:\n%s", got)
+	}
+
+	// Single level products have no level label, unless streamed.
+	path = "schema/IDS60920.xml"
+	o := New(file.New(path))
+	err = o.RetrieveAndParse()
+	if err != nil {
+		t.Fatalf("Failed to retrieve and parse '%s': %v", path, err)
+	}
+	if strings.Contains(gatherText(t, o), `level="`) {
+		t.Errorf("Unexpected level label for a single level product")
+	}
+
+	s = NewStreaming(file.New(path))
+	err = s.RetrieveAndParse()
+	if err != nil {
+		t.Fatalf("Failed to retrieve and stream '%s': %v", path, err)
+	}
+	if !strings.Contains(gatherText(t, s), `level="0"`) {
+		t.Errorf("Expected a level label for a streamed product")
+	}
+}
+
+func TestBaseUnits(t *testing.T) {
+	for _, id := range []string{"IDS10034", "IDS60920"} {
+		path := "schema/" + id + ".xml"
+
+		m := New(file.New(path)).WithBaseUnits()
+		err := m.RetrieveAndParse()
+		if err != nil {
+			t.Fatalf("Failed to retrieve and parse '%s': %v", path, err)
+		}
+
+		problems, err := testutil.CollectAndLint(m)
+		if err != nil {
+			t.Errorf("CollectAndLint failed: %v", err)
+		}
+		if len(problems) > 0 {
+			t.Errorf("%s: %v", id, problems)
+		}
+
+		s := NewStreaming(file.New(path)).WithBaseUnits()
+		err = s.RetrieveAndParse()
+		if err != nil {
+			t.Fatalf("Failed to retrieve and stream '%s': %v", path, err)
+		}
+		if gatherText(t, s) != gatherText(t, withLevels(m)) {
+			t.Errorf("%s: streamed metrics differ from parsed metrics", id)
+		}
+
+		if strings.Contains(gatherText(t, m), "units=") {
+			t.Errorf("%s: units label exported with base units", id)
+		}
+	}
+}
+
+func TestCAPCollector(t *testing.T) {
+	points := []cap.PointOfInterest{{Name: "adelaide", Point: schema.Point{Latitude: -34.93, Longitude: 138.6}}}
+
+	for _, m := range []*Metric{New(file.New("schema/cap.xml")), NewStreaming(file.New("schema/cap.xml"))} {
+		m.WithPointsOfInterest(points...)
+		err := m.RetrieveAndParse()
+		if err != nil {
+			t.Fatalf("Failed to retrieve and parse alert: %v", err)
+		}
+
+		// 3 areas, 3 expiry times and the point tested against 2 polygons
+		count := testutil.CollectAndCount(m, cap.MetricNames...)
+		if count != 8 {
+			t.Errorf("Got %d metrics, expected %d", count, 8)
+		}
+	}
+}
+
+// benchmarkCollect retrieves and collects the fixture for id, also reporting
+// the heap retained by a loaded Metric between scrapes.
+func benchmarkCollect(b *testing.B, id string, newMetric func(connection.Retriever) *Metric) {
+	level := log.GetLevel()
+	log.SetLevel(log.WarnLevel)
+	defer log.SetLevel(level)
+
+	r := file.New("schema/" + id + ".xml")
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	m := newMetric(r)
+	err := m.RetrieveAndParse()
+	if err != nil {
+		b.Fatalf("Failed to parse '%s': %v", id, err)
+	}
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	runtime.KeepAlive(m)
+	m.Close()
+
+	b.ResetTimer()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		m := newMetric(r)
+		err := m.RetrieveAndParse()
+		if err != nil {
+			b.Fatalf("Failed to parse '%s': %v", id, err)
+		}
+		testutil.CollectAndCount(m)
+	}
+	b.ReportMetric(float64(after.HeapAlloc)-float64(before.HeapAlloc), "retained-B")
+}
+
+func BenchmarkParseIDS10044(b *testing.B) {
+	benchmarkCollect(b, "IDS10044", New)
+}
+
+func BenchmarkStreamIDS10044(b *testing.B) {
+	benchmarkCollect(b, "IDS10044", NewStreaming)
+}
+
+func BenchmarkParseIDS60920(b *testing.B) {
+	benchmarkCollect(b, "IDS60920", New)
+}
+
+func BenchmarkStreamIDS60920(b *testing.B) {
+	benchmarkCollect(b, "IDS60920", NewStreaming)
+}
@@ -0,0 +1,100 @@
+package observations
+
+import (
+	"strconv"
+	"strings"
+)
+
+// compassDegrees maps the compass points of wind directions to degrees,
+// following the BoM convention of 360 for north and 0 for calm.
+var compassDegrees = map[string]float64{
+	"CALM": 0,
+	"N":    360,
+	"NNE":  22.5,
+	"NE":   45,
+	"ENE":  67.5,
+	"E":    90,
+	"ESE":  112.5,
+	"SE":   135,
+	"SSE":  157.5,
+	"S":    180,
+	"SSW":  202.5,
+	"SW":   225,
+	"WSW":  247.5,
+	"W":    270,
+	"WNW":  292.5,
+	"NW":   315,
+	"NNW":  337.5,
+}
+
+// CompassDegrees returns the direction in degrees of a compass point, eg. 22.5
+// for "NNE", or 0 for "CALM".
+func CompassDegrees(s string) (float64, bool) {
+	d, ok := compassDegrees[strings.ToUpper(strings.TrimSpace(s))]
+	return d, ok
+}
+
+// CloudType describes a cloud_type_id code.
+type CloudType struct {
+	Genus       string
+	Description string
+}
+
+// CloudTypes maps cloud_type_id codes to cloud genera. The codes are those of
+// WMO BUFR code table 0 20 012: genera (0-9), then high (10-19), middle
+// (20-29) and low (30-39) cloud types, where the first of each group reports
+// no cloud of that level.
+var CloudTypes = map[int]CloudType{
+	0:  {"Cirrus", "Cirrus"},
+	1:  {"Cirrocumulus", "Cirrocumulus"},
+	2:  {"Cirrostratus", "Cirrostratus"},
+	3:  {"Altocumulus", "Altocumulus"},
+	4:  {"Altostratus", "Altostratus"},
+	5:  {"Nimbostratus", "Nimbostratus"},
+	6:  {"Stratocumulus", "Stratocumulus"},
+	7:  {"Stratus", "Stratus"},
+	8:  {"Cumulus", "Cumulus"},
+	9:  {"Cumulonimbus", "Cumulonimbus"},
+	10: {"None", "No high cloud"},
+	11: {"Cirrus", "Cirrus fibratus, sometimes uncinus, not progressively invading the sky"},
+	12: {"Cirrus", "Cirrus spissatus, in patches or entangled sheaves"},
+	13: {"Cirrus", "Cirrus spissatus cumulonimbogenitus"},
+	14: {"Cirrus", "Cirrus uncinus or fibratus, progressively invading the sky"},
+	15: {"Cirrostratus", "Cirrus and cirrostratus, or cirrostratus alone, below 45 degrees"},
+	16: {"Cirrostratus", "Cirrus and cirrostratus, or cirrostratus alone, above 45 degrees"},
+	17: {"Cirrostratus", "Cirrostratus covering the whole sky"},
+	18: {"Cirrostratus", "Cirrostratus not progressively invading the sky"},
+	19: {"Cirrocumulus", "Cirrocumulus alone, or predominant"},
+	20: {"None", "No middle cloud"},
+	21: {"Altostratus", "Altostratus translucidus"},
+	22: {"Altostratus", "Altostratus opacus or nimbostratus"},
+	23: {"Altocumulus", "Altocumulus translucidus at a single level"},
+	24: {"Altocumulus", "Patches of altocumulus translucidus, continually changing"},
+	25: {"Altocumulus", "Altocumulus translucidus in bands, progressively invading the sky"},
+	26: {"Altocumulus", "Altocumulus cumulogenitus or cumulonimbogenitus"},
+	27: {"Altocumulus", "Altocumulus duplicatus or opacus, or with altostratus or nimbostratus"},
+	28: {"Altocumulus", "Altocumulus castellanus or floccus"},
+	29: {"Altocumulus", "Altocumulus of a chaotic sky"},
+	30: {"None", "No low cloud"},
+	31: {"Cumulus", "Cumulus humilis or fractus"},
+	32: {"Cumulus", "Cumulus mediocris or congestus"},
+	33: {"Cumulonimbus", "Cumulonimbus calvus"},
+	34: {"Stratocumulus", "Stratocumulus cumulogenitus"},
+	35: {"Stratocumulus", "Stratocumulus other than cumulogenitus"},
+	36: {"Stratus", "Stratus nebulosus or fractus"},
+	37: {"Stratus", "Stratus fractus or cumulus fractus of bad weather"},
+	38: {"Cumulus", "Cumulus and stratocumulus at different levels"},
+	39: {"Cumulonimbus", "Cumulonimbus capillatus"},
+	59: {"Unknown", "Cloud not visible owing to darkness, fog, dust or other phenomena"},
+}
+
+// ParseCloudType returns the cloud type of a cloud_type_id value.
+func ParseCloudType(s string) (CloudType, bool) {
+	id, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil {
+		return CloudType{}, false
+	}
+
+	c, ok := CloudTypes[id]
+	return c, ok
+}
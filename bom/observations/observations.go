@@ -2,10 +2,12 @@ package observations
 
 import (
 	"fmt"
+	"github.com/gkoh/bom_exporter/bom/quantity"
 	"github.com/gkoh/bom_exporter/bom/schema"
 	"github.com/prometheus/client_golang/prometheus"
 	log "github.com/sirupsen/logrus"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -20,86 +22,194 @@ var MetricNames = []string{
 	"bom_observations_cloud_cover",
 	"bom_observations_wind_direction",
 	"bom_observations_rainfall",
+	"bom_observations_temperature_time_seconds",
+	"bom_observations_rainfall_window_start_time_seconds",
+	"bom_observations_rainfall_window_end_time_seconds",
+	"bom_observations_station_info",
+	"bom_observations_station_height_meters",
+	"bom_observations_weather_info",
+	"bom_observations_cloud_info",
+	"bom_observations_cloud_type",
+	"bom_observations_wind_compass",
+	"bom_observations_max_wind_gust",
+	"bom_observations_max_wind_gust_time_seconds",
+}
+
+// BaseUnitMetricNames is the list of metrics exported by the observations
+// collector when using base units.
+var BaseUnitMetricNames = []string{
+	"bom_observations_temperature_celsius",
+	"bom_observations_wind_speed_meters_per_second",
+	"bom_observations_humidity_ratio",
+	"bom_observations_pressure_pascals",
+	"bom_observations_visibility_meters",
+	"bom_observations_cloud_base_meters",
+	"bom_observations_cloud_cover_oktas",
+	"bom_observations_wind_direction_degrees",
+	"bom_observations_rainfall_meters",
+	"bom_observations_temperature_time_seconds",
+	"bom_observations_rainfall_window_start_time_seconds",
+	"bom_observations_rainfall_window_end_time_seconds",
+	"bom_observations_station_info",
+	"bom_observations_station_height_meters",
+	"bom_observations_weather_info",
+	"bom_observations_cloud_info",
+	"bom_observations_cloud_type",
+	"bom_observations_wind_compass_degrees",
+	"bom_observations_max_wind_gust_meters_per_second",
+	"bom_observations_max_wind_gust_time_seconds",
 }
 
 // Observations combines unmarshalled observations data and the corresponding
 // Prometheus metrics.
 type Observations struct {
-	product         schema.Product
-	temperatureDesc *prometheus.Desc
-	windSpeedDesc   *prometheus.Desc
-	humidityDesc    *prometheus.Desc
-	pressureDesc    *prometheus.Desc
-	visibilityDesc  *prometheus.Desc
-	cloudBaseDesc   *prometheus.Desc
-	cloudDesc       *prometheus.Desc
-	windDirDesc     *prometheus.Desc
-	rainfallDesc    *prometheus.Desc
+	product         *schema.Product
+	baseUnits       bool
+	levels          bool
+	temperatureDesc quantity.Desc
+	windSpeedDesc   quantity.Desc
+	humidityDesc    quantity.Desc
+	pressureDesc    quantity.Desc
+	visibilityDesc  quantity.Desc
+	cloudBaseDesc   quantity.Desc
+	cloudDesc       quantity.Desc
+	windDirDesc     quantity.Desc
+	compassDesc     quantity.Desc
+	maxGustDesc     quantity.Desc
+	rainfallDesc    quantity.Desc
+	tempTimeDesc    *prometheus.Desc
+	gustTimeDesc    *prometheus.Desc
+	rainStartDesc   *prometheus.Desc
+	rainEndDesc     *prometheus.Desc
+	stationDesc     *prometheus.Desc
+	heightDesc      *prometheus.Desc
+	weatherDesc     *prometheus.Desc
+	conditionDesc   *prometheus.Desc
+	cloudTypeDesc   *prometheus.Desc
 }
 
+// stationLabels are the labels common to all station metrics.
+var stationLabels = []string{"bom_id", "wmo_id", "station_name", "latitude", "longitude", "description", "region"}
+
+// periodLabels are the labels common to all metrics observed in a period,
+// followed by level for products with several levels per station.
+var periodLabels = []string{"bom_id", "wmo_id", "station_name", "latitude", "longitude", "description", "region", "index"}
+
 // New creates a new observations collector.
-func New(product schema.Product) *Observations {
+func New(product *schema.Product) *Observations {
+	return newObservations(product, false)
+}
+
+// NewWithBaseUnits creates a new observations collector exporting each
+// quantity once, converted to its base unit which is named in the metric name,
+// eg. bom_observations_wind_speed_meters_per_second.
+func NewWithBaseUnits(product *schema.Product) *Observations {
+	return newObservations(product, true)
+}
+
+func newObservations(product *schema.Product, baseUnits bool) *Observations {
 	var o Observations
 
 	o.product = product
+	o.baseUnits = baseUnits
 
-	labels := prometheus.Labels{"identifier": product.Amoc.Identifier}
+	for i := range product.Observations.Station {
+		o.levels = o.levels || MultiLevel(&product.Observations.Station[i])
+	}
 
-	o.temperatureDesc = prometheus.NewDesc(
-		prometheus.BuildFQName("bom", "observations", "temperature"),
-		"Temperature observation.",
-		[]string{"bom_id", "wmo_id", "station_name", "latitude", "longitude", "description", "region", "index", "type", "units"},
-		labels)
+	o.describe()
+	return &o
+}
 
-	o.windSpeedDesc = prometheus.NewDesc(
-		prometheus.BuildFQName("bom", "observations", "wind_speed"),
-		"Wind speed.",
-		[]string{"bom_id", "wmo_id", "station_name", "latitude", "longitude", "description", "region", "index", "type", "units"},
+// WithLevels adds the level label to the period metrics, as if a station had
+// several levels. It is needed when stations are collected as they are
+// decoded, see schema.Decode, and returns o.
+func (o *Observations) WithLevels() *Observations {
+	o.levels = true
+	o.describe()
+	return o
+}
+
+// MultiLevel reports whether any period of station has more than one level.
+// Products with such stations add a level label to the period metrics.
+func MultiLevel(station *schema.Station) bool {
+	for _, p := range station.Period {
+		if len(p.Level) > 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// describe creates the metric descriptions.
+func (o *Observations) describe() {
+	labels := prometheus.Labels{"identifier": o.product.Amoc.Identifier}
+
+	o.temperatureDesc = o.quantityDesc("temperature", "Temperature observation.", schema.UnitCelsius, "celsius", "type")
+	o.windSpeedDesc = o.quantityDesc("wind_speed", "Wind speed.", schema.UnitMetersPerSecond, "meters_per_second", "type")
+	o.humidityDesc = o.quantityDesc("humidity", "Relative humidity.", schema.UnitRatio, "ratio")
+	o.pressureDesc = o.quantityDesc("pressure", "Atmospheric pressure.", schema.UnitPascals, "pascals", "type")
+	o.visibilityDesc = o.quantityDesc("visibility", "Visibility.", schema.UnitMeters, "meters")
+	o.cloudBaseDesc = o.quantityDesc("cloud_base", "Cloud base.", schema.UnitMeters, "meters")
+	o.cloudDesc = o.quantityDesc("cloud_cover", "Cloud cover.", schema.UnitOktas, "oktas")
+	o.windDirDesc = o.quantityDesc("wind_direction", "Wind direction.", schema.UnitDegrees, "degrees")
+	o.compassDesc = o.quantityDesc("wind_compass", "Average wind direction as a compass point, in degrees.", schema.UnitDegrees, "degrees", "direction")
+	o.maxGustDesc = o.quantityDesc("max_wind_gust", "Maximum wind gust of the day.", schema.UnitMetersPerSecond, "meters_per_second", "direction")
+	o.rainfallDesc = o.quantityDesc("rainfall", "Rainfall.", schema.UnitMeters, "meters", "type")
+
+	o.tempTimeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("bom", "observations", "temperature_time_seconds"),
+		"Time at which the temperature extreme occurred, in seconds since the epoch.",
+		o.periodLabelNames("type"),
 		labels)
 
-	o.humidityDesc = prometheus.NewDesc(
-		prometheus.BuildFQName("bom", "observations", "humidity"),
-		"Relative humidity.",
-		[]string{"bom_id", "wmo_id", "station_name", "latitude", "longitude", "description", "region", "index", "units"},
+	o.gustTimeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("bom", "observations", "max_wind_gust_time_seconds"),
+		"Time at which the maximum wind gust of the day occurred, in seconds since the epoch.",
+		o.periodLabelNames(),
 		labels)
 
-	o.pressureDesc = prometheus.NewDesc(
-		prometheus.BuildFQName("bom", "observations", "pressure"),
-		"Atmospheric pressure.",
-		[]string{"bom_id", "wmo_id", "station_name", "latitude", "longitude", "description", "region", "index", "type", "units"},
+	o.rainStartDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("bom", "observations", "rainfall_window_start_time_seconds"),
+		"Start of the rainfall accumulation window, in seconds since the epoch.",
+		o.periodLabelNames("type"),
 		labels)
 
-	o.visibilityDesc = prometheus.NewDesc(
-		prometheus.BuildFQName("bom", "observations", "visibility"),
-		"Visibility.",
-		[]string{"bom_id", "wmo_id", "station_name", "latitude", "longitude", "description", "region", "index", "units"},
+	o.rainEndDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("bom", "observations", "rainfall_window_end_time_seconds"),
+		"End of the rainfall accumulation window, in seconds since the epoch.",
+		o.periodLabelNames("type"),
 		labels)
 
-	o.cloudBaseDesc = prometheus.NewDesc(
-		prometheus.BuildFQName("bom", "observations", "cloud_base"),
-		"Cloud base.",
-		[]string{"bom_id", "wmo_id", "station_name", "latitude", "longitude", "description", "region", "index", "units"},
+	o.stationDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("bom", "observations", "station_info"),
+		"Station metadata, value is always 1. The wind_src label is that of the most recent period.",
+		append(stationLabels, "timezone", "station_type", "forecast_district_id", "wind_src"),
 		labels)
 
-	o.cloudDesc = prometheus.NewDesc(
-		prometheus.BuildFQName("bom", "observations", "cloud_cover"),
-		"Cloud cover.",
-		[]string{"bom_id", "wmo_id", "station_name", "latitude", "longitude", "description", "region", "index", "units"},
+	o.heightDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("bom", "observations", "station_height_meters"),
+		"Station height above mean sea level in meters.",
+		stationLabels,
 		labels)
 
-	o.windDirDesc = prometheus.NewDesc(
-		prometheus.BuildFQName("bom", "observations", "wind_direction"),
-		"Wind direction.",
-		[]string{"bom_id", "wmo_id", "station_name", "latitude", "longitude", "description", "region", "index", "units"},
+	o.weatherDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("bom", "observations", "weather_info"),
+		"Present weather, value is always 1. The condition label holds the weather observed (eg. 'Fine').",
+		o.periodLabelNames("condition"),
 		labels)
 
-	o.rainfallDesc = prometheus.NewDesc(
-		prometheus.BuildFQName("bom", "observations", "rainfall"),
-		"Rainfall.",
-		[]string{"bom_id", "wmo_id", "station_name", "latitude", "longitude", "description", "region", "index", "type", "units"},
+	o.conditionDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("bom", "observations", "cloud_info"),
+		"Sky condition, value is always 1. The condition label holds the sky observed (eg. 'Partly cloudy').",
+		o.periodLabelNames("condition"),
 		labels)
 
-	return &o
+	o.cloudTypeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("bom", "observations", "cloud_type"),
+		"Cloud type, value is the cloud_type_id code with the genus label decoded from it.",
+		o.periodLabelNames("genus", "cloud_type"),
+		labels)
 }
 
 var temperatureLabelMap map[string]string = map[string]string{"apparent_temp": "apparent",
@@ -121,203 +231,322 @@ var pressureTypeLabelMap map[string]string = map[string]string{"pres": "absolute
 	"qnh_pres": "qnh",
 }
 
-func (o *Observations) processPeriod(station *schema.Station, ch chan<- prometheus.Metric) {
-	region := o.product.Amoc.Source.Region
-	for _, e := range station.Period.Level.Element {
-		log.Infof("Type: %s, Value: %s, Units: %s", e.Type, e.Value, e.Unit)
-		switch e.Type {
-		case "apparent_temp", "air_temperature", "maximum_air_temperature", "minimum_air_temperature", "dew_point", "delta_t":
-			v, err := strconv.ParseFloat(e.Value, 64)
-			if err == nil {
-				ch <- prometheus.NewMetricWithTimestamp(time.Time(station.Period.TimeUTC),
-					prometheus.MustNewConstMetric(
-						o.temperatureDesc,
-						prometheus.GaugeValue,
-						v,
-						station.WmoID,
-						station.BomID,
-						station.Name,
-						fmt.Sprintf("%f", station.Latitude),
-						fmt.Sprintf("%f", station.Longitude),
-						station.Description,
-						region,
-						station.Period.Index,
-						temperatureLabelMap[e.Type],
-						e.Unit))
-			}
-		case "gust_kmh", "wind_gust_spd", "wind_spd_kmh", "wind_spd":
-			v, err := strconv.ParseFloat(e.Value, 64)
-			if err == nil {
-				ch <- prometheus.NewMetricWithTimestamp(time.Time(station.Period.TimeUTC),
-					prometheus.MustNewConstMetric(
-						o.windSpeedDesc,
-						prometheus.GaugeValue,
-						v,
-						station.WmoID,
-						station.BomID,
-						station.Name,
-						fmt.Sprintf("%f", station.Latitude),
-						fmt.Sprintf("%f", station.Longitude),
-						station.Description,
-						region,
-						station.Period.Index,
-						windTypeLabelMap[e.Type],
-						e.Unit))
-			}
-		case "rel-humidity":
-			v, err := strconv.ParseFloat(e.Value, 64)
-			if err == nil {
-				ch <- prometheus.NewMetricWithTimestamp(time.Time(station.Period.TimeUTC),
-					prometheus.MustNewConstMetric(
-						o.humidityDesc,
-						prometheus.GaugeValue,
-						v,
-						station.WmoID,
-						station.BomID,
-						station.Name,
-						fmt.Sprintf("%f", station.Latitude),
-						fmt.Sprintf("%f", station.Longitude),
-						station.Description,
-						region,
-						station.Period.Index,
-						e.Unit))
-			}
-		case "pres", "msl_pres", "qnh_pres":
-			v, err := strconv.ParseFloat(e.Value, 64)
-			if err == nil {
-				ch <- prometheus.NewMetricWithTimestamp(time.Time(station.Period.TimeUTC),
-					prometheus.MustNewConstMetric(
-						o.pressureDesc,
-						prometheus.GaugeValue,
-						v,
-						station.WmoID,
-						station.BomID,
-						station.Name,
-						fmt.Sprintf("%f", station.Latitude),
-						fmt.Sprintf("%f", station.Longitude),
-						station.Description,
-						region,
-						station.Period.Index,
-						pressureTypeLabelMap[e.Type],
-						e.Unit))
-			}
-		case "vis_km":
-			v, err := strconv.ParseFloat(e.Value, 64)
-			if err == nil {
-				ch <- prometheus.NewMetricWithTimestamp(time.Time(station.Period.TimeUTC),
-					prometheus.MustNewConstMetric(
-						o.visibilityDesc,
-						prometheus.GaugeValue,
-						v,
-						station.WmoID,
-						station.BomID,
-						station.Name,
-						fmt.Sprintf("%f", station.Latitude),
-						fmt.Sprintf("%f", station.Longitude),
-						station.Description,
-						region,
-						station.Period.Index,
-						e.Unit))
-			}
-		case "cloud_base_m":
-			v, err := strconv.ParseFloat(e.Value, 64)
-			if err == nil {
-				ch <- prometheus.NewMetricWithTimestamp(time.Time(station.Period.TimeUTC),
-					prometheus.MustNewConstMetric(
-						o.cloudBaseDesc,
-						prometheus.GaugeValue,
-						v,
-						station.WmoID,
-						station.BomID,
-						station.Name,
-						fmt.Sprintf("%f", station.Latitude),
-						fmt.Sprintf("%f", station.Longitude),
-						station.Description,
-						region,
-						station.Period.Index,
-						e.Unit))
-			}
-		case "cloud_oktas":
-			v, err := strconv.ParseFloat(e.Value, 64)
-			if err == nil {
-				ch <- prometheus.NewMetricWithTimestamp(time.Time(station.Period.TimeUTC),
-					prometheus.MustNewConstMetric(
-						o.cloudDesc,
-						prometheus.GaugeValue,
-						v,
-						station.WmoID,
-						station.BomID,
-						station.Name,
-						fmt.Sprintf("%f", station.Latitude),
-						fmt.Sprintf("%f", station.Longitude),
-						station.Description,
-						region,
-						station.Period.Index,
-						"oktas"))
-			}
+// quantityDesc creates the description of a quantity metric, see
+// quantity.NewDesc.
+func (o *Observations) quantityDesc(name string, help string, unit schema.Unit, suffix string, extra ...string) quantity.Desc {
+	return quantity.NewDesc("observations", name, help, unit, suffix, o.baseUnits,
+		o.periodLabelNames(extra...), prometheus.Labels{"identifier": o.product.Amoc.Identifier})
+}
 
-		case "wind_dir_deg":
-			v, err := strconv.ParseFloat(e.Value, 64)
-			if err == nil {
-				ch <- prometheus.NewMetricWithTimestamp(time.Time(station.Period.TimeUTC),
-					prometheus.MustNewConstMetric(
-						o.windDirDesc,
-						prometheus.GaugeValue,
-						v,
-						station.WmoID,
-						station.BomID,
-						station.Name,
-						fmt.Sprintf("%f", station.Latitude),
-						fmt.Sprintf("%f", station.Longitude),
-						station.Description,
-						region,
-						station.Period.Index,
-						e.Unit))
-			}
+// periodLabelNames returns the labels common to all period metrics followed by
+// extra.
+func (o *Observations) periodLabelNames(extra ...string) []string {
+	labels := append([]string{}, periodLabels...)
+	if o.levels {
+		labels = append(labels, "level")
+	}
+	return append(labels, extra...)
+}
 
-		case "rainfall":
-			v, err := strconv.ParseFloat(e.Value, 64)
-			if err == nil {
-				ch <- prometheus.NewMetricWithTimestamp(time.Time(station.Period.TimeUTC),
-					prometheus.MustNewConstMetric(
-						o.rainfallDesc,
-						prometheus.GaugeValue,
-						v,
-						station.WmoID,
-						station.BomID,
-						station.Name,
-						fmt.Sprintf("%f", station.Latitude),
-						fmt.Sprintf("%f", station.Longitude),
-						station.Description,
-						region,
-						station.Period.Index,
-						"9am",
-						e.Unit))
-			}
+// stationValues returns the values of the common station labels. The ids are
+// in the order exported since the first release, so bom_id holds the WMO id
+// and wmo_id the BoM id.
+func (o *Observations) stationValues(station *schema.Station) []string {
+	return []string{
+		station.WmoID,
+		station.BomID,
+		station.Name,
+		fmt.Sprintf("%f", station.Latitude),
+		fmt.Sprintf("%f", station.Longitude),
+		station.Description,
+		o.product.Amoc.Source.Region}
+}
+
+// periodMetric creates a gauge timestamped at the observation period, with
+// the common station, period and level label values followed by extra.
+func (o *Observations) periodMetric(desc *prometheus.Desc, v float64, station *schema.Station, period *schema.Period, level *schema.Level, extra ...string) prometheus.Metric {
+	values := append(o.stationValues(station), period.Index)
+	if o.levels {
+		values = append(values, level.Index)
+	}
+
+	return timestamped(time.Time(period.TimeUTC),
+		prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, v, append(values, extra...)...))
+}
+
+// quantityMetric sends a quantity, converted to the base unit of desc if
+// required. Otherwise it is sent as found, labelled with units as spelt in the
+// product. Quantities which cannot be converted are skipped.
+func (o *Observations) quantityMetric(desc quantity.Desc, q schema.Quantity, units string, station *schema.Station, period *schema.Period, level *schema.Level, ch chan<- prometheus.Metric, extra ...string) {
+	if !o.baseUnits {
+		ch <- o.periodMetric(desc.Desc, q.Value, station, period, level, append(extra, units)...)
+		return
+	}
+
+	c, err := q.Convert(desc.Unit)
+	if err != nil {
+		log.Warnf("Skipping %s at '%s': %s", desc.Desc, station.Name, err)
+		return
+	}
+	ch <- o.periodMetric(desc.Desc, c.Value, station, period, level, extra...)
+}
+
+// processWindow emits the accumulation window of a rainfall element.
+func (o *Observations) processWindow(station *schema.Station, period *schema.Period, level *schema.Level, e *schema.Element, rainType string, ch chan<- prometheus.Metric) {
+	if e.StartTimeUTC != nil {
+		ch <- o.periodMetric(o.rainStartDesc, float64(time.Time(*e.StartTimeUTC).Unix()), station, period, level, rainType)
+	}
+	if e.EndTimeUTC != nil {
+		ch <- o.periodMetric(o.rainEndDesc, float64(time.Time(*e.EndTimeUTC).Unix()), station, period, level, rainType)
+	}
+}
+
+// categoryFunc exports a categorical (non numeric) observation element.
+type categoryFunc func(o *Observations, station *schema.Station, period *schema.Period, level *schema.Level, e *schema.Element, ch chan<- prometheus.Metric)
+
+// categoryFuncs maps the categorical element types decoded by the collector
+// to the function exporting them, see DecodesElement. The maximum_gust_dir
+// element is exported as the direction label of the maximum wind gust.
+var categoryFuncs = map[string]categoryFunc{
+	"weather":          (*Observations).processWeather,
+	"cloud":            (*Observations).processCloud,
+	"cloud_type_id":    (*Observations).processCloudType,
+	"wind_dir":         (*Observations).processCompass,
+	"maximum_gust_dir": nil,
+}
+
+// quantityFunc exports an observation element holding a quantity.
+type quantityFunc func(o *Observations, station *schema.Station, period *schema.Period, level *schema.Level, e *schema.Element, q schema.Quantity, ch chan<- prometheus.Metric)
+
+// quantityFuncs maps the quantity element types decoded by the collector to
+// the function exporting them, see DecodesElement.
+var quantityFuncs = map[string]quantityFunc{
+	"apparent_temp":           (*Observations).processTemperature,
+	"air_temperature":         (*Observations).processTemperature,
+	"maximum_air_temperature": (*Observations).processTemperature,
+	"minimum_air_temperature": (*Observations).processTemperature,
+	"dew_point":               (*Observations).processTemperature,
+	"delta_t":                 (*Observations).processTemperature,
+	"gust_kmh":                (*Observations).processWindSpeed,
+	"wind_gust_spd":           (*Observations).processWindSpeed,
+	"wind_spd_kmh":            (*Observations).processWindSpeed,
+	"wind_spd":                (*Observations).processWindSpeed,
+	"maximum_gust_spd":        (*Observations).processMaxGust,
+	"maximum_gust_kmh":        (*Observations).processMaxGust,
+	"rel-humidity":            (*Observations).processHumidity,
+	"pres":                    (*Observations).processPressure,
+	"msl_pres":                (*Observations).processPressure,
+	"qnh_pres":                (*Observations).processPressure,
+	"vis_km":                  (*Observations).processVisibility,
+	"cloud_base_m":            (*Observations).processCloudBase,
+	"cloud_oktas":             (*Observations).processCloudCover,
+	"wind_dir_deg":            (*Observations).processWindDirection,
+	"rainfall":                (*Observations).processRainfall,
+	"rainfall_24hr":           (*Observations).processRainfall,
+}
+
+// DecodesElement reports whether the collector exports elements of the given
+// type.
+func DecodesElement(elementType string) bool {
+	_, category := categoryFuncs[elementType]
+	_, quantity := quantityFuncs[elementType]
+	return category || quantity
+}
+
+func (o *Observations) processWeather(station *schema.Station, period *schema.Period, level *schema.Level, e *schema.Element, ch chan<- prometheus.Metric) {
+	if value := strings.TrimSpace(e.Value); value != "" && value != "-" {
+		ch <- o.periodMetric(o.weatherDesc, 1.0, station, period, level, value)
+	}
+}
+
+func (o *Observations) processCloud(station *schema.Station, period *schema.Period, level *schema.Level, e *schema.Element, ch chan<- prometheus.Metric) {
+	if value := strings.TrimSpace(e.Value); value != "" && value != "-" {
+		ch <- o.periodMetric(o.conditionDesc, 1.0, station, period, level, value)
+	}
+}
+
+func (o *Observations) processCloudType(station *schema.Station, period *schema.Period, level *schema.Level, e *schema.Element, ch chan<- prometheus.Metric) {
+	value := strings.TrimSpace(e.Value)
+	c, ok := ParseCloudType(value)
+	if !ok {
+		log.Debugf("Unknown cloud_type_id '%s' at '%s'", value, station.Name)
+		return
+	}
+	id, _ := strconv.Atoi(value)
+	ch <- o.periodMetric(o.cloudTypeDesc, float64(id), station, period, level, c.Genus, c.Description)
+}
+
+func (o *Observations) processCompass(station *schema.Station, period *schema.Period, level *schema.Level, e *schema.Element, ch chan<- prometheus.Metric) {
+	value := strings.TrimSpace(e.Value)
+	d, ok := CompassDegrees(value)
+	if !ok {
+		log.Debugf("Unknown wind_dir '%s' at '%s'", value, station.Name)
+		return
+	}
+	q := schema.Quantity{Value: d, Unit: schema.UnitDegrees}
+	o.quantityMetric(o.compassDesc, q, q.Unit.String(), station, period, level, ch, strings.ToUpper(value))
+}
+
+// kmhTypes maps the knots wind elements to their km/h equivalents. With base
+// units only one of each is exported, preferring km/h.
+var kmhTypes = map[string]string{"wind_gust_spd": "gust_kmh",
+	"wind_spd":         "wind_spd_kmh",
+	"maximum_gust_spd": "maximum_gust_kmh",
+}
+
+// element returns the element of the given type in level, or nil if there is
+// none.
+func element(level *schema.Level, elementType string) *schema.Element {
+	for i := range level.Element {
+		if level.Element[i].Type == elementType {
+			return &level.Element[i]
+		}
+	}
+	return nil
+}
+
+func (o *Observations) processTemperature(station *schema.Station, period *schema.Period, level *schema.Level, e *schema.Element, q schema.Quantity, ch chan<- prometheus.Metric) {
+	o.quantityMetric(o.temperatureDesc, q, e.Unit, station, period, level, ch, temperatureLabelMap[e.Type])
+	if e.TimeUTC != nil {
+		ch <- o.periodMetric(o.tempTimeDesc, float64(time.Time(*e.TimeUTC).Unix()), station, period, level, temperatureLabelMap[e.Type])
+	}
+}
+
+func (o *Observations) processWindSpeed(station *schema.Station, period *schema.Period, level *schema.Level, e *schema.Element, q schema.Quantity, ch chan<- prometheus.Metric) {
+	if o.baseUnits && element(level, kmhTypes[e.Type]) != nil {
+		return
+	}
+	o.quantityMetric(o.windSpeedDesc, q, e.Unit, station, period, level, ch, windTypeLabelMap[e.Type])
+}
+
+// processMaxGust sends the maximum wind gust, labelled with the direction
+// given by the maximum_gust_dir element, and the time at which it occurred.
+func (o *Observations) processMaxGust(station *schema.Station, period *schema.Period, level *schema.Level, e *schema.Element, q schema.Quantity, ch chan<- prometheus.Metric) {
+	kmh := element(level, kmhTypes[e.Type]) != nil
+	if o.baseUnits && kmh {
+		return
+	}
+
+	var dir string
+	if d := element(level, "maximum_gust_dir"); d != nil {
+		dir = strings.ToUpper(strings.TrimSpace(d.Value))
+	}
+	o.quantityMetric(o.maxGustDesc, q, e.Unit, station, period, level, ch, dir)
+
+	// Both speeds carry the same time, export it once.
+	if e.TimeUTC != nil && !kmh {
+		ch <- o.periodMetric(o.gustTimeDesc, float64(time.Time(*e.TimeUTC).Unix()), station, period, level)
+	}
+}
+
+func (o *Observations) processHumidity(station *schema.Station, period *schema.Period, level *schema.Level, e *schema.Element, q schema.Quantity, ch chan<- prometheus.Metric) {
+	o.quantityMetric(o.humidityDesc, q, e.Unit, station, period, level, ch)
+}
+
+func (o *Observations) processPressure(station *schema.Station, period *schema.Period, level *schema.Level, e *schema.Element, q schema.Quantity, ch chan<- prometheus.Metric) {
+	o.quantityMetric(o.pressureDesc, q, e.Unit, station, period, level, ch, pressureTypeLabelMap[e.Type])
+}
+
+func (o *Observations) processVisibility(station *schema.Station, period *schema.Period, level *schema.Level, e *schema.Element, q schema.Quantity, ch chan<- prometheus.Metric) {
+	o.quantityMetric(o.visibilityDesc, q, e.Unit, station, period, level, ch)
+}
+
+func (o *Observations) processCloudBase(station *schema.Station, period *schema.Period, level *schema.Level, e *schema.Element, q schema.Quantity, ch chan<- prometheus.Metric) {
+	o.quantityMetric(o.cloudBaseDesc, q, e.Unit, station, period, level, ch)
+}
+
+func (o *Observations) processCloudCover(station *schema.Station, period *schema.Period, level *schema.Level, e *schema.Element, q schema.Quantity, ch chan<- prometheus.Metric) {
+	q.Unit = schema.UnitOktas
+	o.quantityMetric(o.cloudDesc, q, q.Unit.String(), station, period, level, ch)
+}
+
+func (o *Observations) processWindDirection(station *schema.Station, period *schema.Period, level *schema.Level, e *schema.Element, q schema.Quantity, ch chan<- prometheus.Metric) {
+	o.quantityMetric(o.windDirDesc, q, e.Unit, station, period, level, ch)
+}
+
+// rainfallType returns the type label of a rainfall element, taken from its
+// accumulation window. That is the local time rainfall accumulates since for
+// rainfall (eg. '9am'), or the length of the window for rainfall_24hr (eg.
+// '24hr'). Elements without a window are labelled '9am' and '24hr'
+// respectively, as the BoM documents them.
+func rainfallType(e *schema.Element) string {
+	if e.Type == "rainfall_24hr" {
+		if w := e.Window(); w > 0 {
+			return fmt.Sprintf("%ghr", w.Hours())
+		}
+		return "24hr"
+	}
+
+	if e.StartTimeLocal == nil {
+		return "9am"
+	}
+	since := time.Time(*e.StartTimeLocal)
+	if since.Minute() != 0 {
+		return since.Format("3:04pm")
+	}
+	return since.Format("3pm")
+}
+
+func (o *Observations) processRainfall(station *schema.Station, period *schema.Period, level *schema.Level, e *schema.Element, q schema.Quantity, ch chan<- prometheus.Metric) {
+	rainType := rainfallType(e)
+	o.quantityMetric(o.rainfallDesc, q, e.Unit, station, period, level, ch, rainType)
+	o.processWindow(station, period, level, e, rainType, ch)
+}
 
-		case "rainfall_24hr":
-			v, err := strconv.ParseFloat(e.Value, 64)
-			if err == nil {
-				ch <- prometheus.NewMetricWithTimestamp(time.Time(station.Period.TimeUTC),
-					prometheus.MustNewConstMetric(
-						o.rainfallDesc,
-						prometheus.GaugeValue,
-						v,
-						station.WmoID,
-						station.BomID,
-						station.Name,
-						fmt.Sprintf("%f", station.Latitude),
-						fmt.Sprintf("%f", station.Longitude),
-						station.Description,
-						region,
-						station.Period.Index,
-						"24hr",
-						e.Unit))
+func (o *Observations) processLevel(station *schema.Station, period *schema.Period, level *schema.Level, ch chan<- prometheus.Metric) {
+	for i := range level.Element {
+		e := &level.Element[i]
+		log.Infof("Type: %s, Value: %s, Units: %s", e.Type, e.Value, e.Unit)
+
+		if process, ok := categoryFuncs[e.Type]; ok {
+			if process != nil {
+				process(o, station, period, level, e, ch)
 			}
+			continue
+		}
 
+		process, ok := quantityFuncs[e.Type]
+		if !ok {
+			continue
+		}
+		q, err := e.Quantity()
+		if err != nil {
+			continue
 		}
+		process(o, station, period, level, e, q, ch)
+	}
+}
+
+// processStation emits the station metadata, timestamped at the first (most
+// recent) period if the station has one. The wind source is a period
+// attribute, only that of the most recent period is exported. The height is
+// skipped if the station has none.
+func (o *Observations) processStation(station *schema.Station, ch chan<- prometheus.Metric) {
+	var period schema.Period
+	if len(station.Period) > 0 {
+		period = station.Period[0]
 	}
 
+	ch <- timestamped(time.Time(period.TimeUTC),
+		prometheus.MustNewConstMetric(o.stationDesc, prometheus.GaugeValue, 1.0,
+			append(o.stationValues(station),
+				station.Timezone,
+				station.Type,
+				station.DistrictID,
+				period.WindSrc)...))
+	if station.Height != nil {
+		ch <- timestamped(time.Time(period.TimeUTC),
+			prometheus.MustNewConstMetric(o.heightDesc, prometheus.GaugeValue, *station.Height,
+				o.stationValues(station)...))
+	}
+}
+
+// timestamped returns m timestamped with t, or m unchanged if t is unset.
+func timestamped(t time.Time, m prometheus.Metric) prometheus.Metric {
+	if t.IsZero() {
+		return m
+	}
+	return prometheus.NewMetricWithTimestamp(t, m)
 }
 
 // Describe implements the Prometheus Collector interface.
@@ -327,8 +556,20 @@ func (o *Observations) Describe(ch chan<- *prometheus.Desc) {
 
 // Collect implements the Prometheus Collector interface.
 func (o *Observations) Collect(ch chan<- prometheus.Metric) {
-	for _, s := range o.product.Observations.Station {
-		o.processPeriod(&s, ch)
+	for i := range o.product.Observations.Station {
+		o.CollectStation(&o.product.Observations.Station[i], ch)
+	}
+}
+
+// CollectStation sends the metrics for a single station to ch. It allows
+// stations to be exported as they are decoded, see schema.Decode.
+func (o *Observations) CollectStation(station *schema.Station, ch chan<- prometheus.Metric) {
+	o.processStation(station, ch)
+	for i := range station.Period {
+		p := &station.Period[i]
+		for j := range p.Level {
+			o.processLevel(station, p, &p.Level[j], ch)
+		}
 	}
 }
 
@@ -3,6 +3,7 @@ package observations
 import (
 	"github.com/gkoh/bom_exporter/bom/schema"
 	"github.com/prometheus/client_golang/prometheus/testutil"
+	"strings"
 	"testing"
 	"time"
 )
@@ -122,7 +123,7 @@ func TestCollector(t *testing.T) {
 
 	period := schema.Period{
 		Index: "0",
-		Level: schema.Level{Element: elements},
+		Level: []schema.Level{{Index: "0", Element: elements}},
 	}
 
 	stations := []schema.Station{
@@ -132,14 +133,14 @@ func TestCollector(t *testing.T) {
 			Description: description,
 			Latitude:    latitude,
 			Longitude:   longitude,
-			Period:      period},
+			Period:      []schema.Period{period}},
 		{WmoID: wmoid + "b",
 			BomID:       bomid + "b",
 			Name:        name + "b",
 			Description: description + "b",
 			Latitude:    latitude,
 			Longitude:   longitude,
-			Period:      period},
+			Period:      []schema.Period{period}},
 	}
 	observations := schema.Observations{Station: stations}
 	product := schema.Product{
@@ -149,12 +150,12 @@ func TestCollector(t *testing.T) {
 			IssueTimeUTC: schema.TimeField(issuetime)},
 		Observations: &observations}
 
-	o := New(product)
+	o := New(&product)
 
 	o.Dump()
 
-	// 2 stations, elements repeated
-	expected := 2 * len(elements)
+	// 2 stations without a height, elements repeated plus station info
+	expected := 2 * (len(elements) + 1)
 
 	count := testutil.CollectAndCount(o, MetricNames...)
 	if count != expected {
@@ -171,3 +172,341 @@ func TestCollector(t *testing.T) {
 	}
 
 }
+
+func TestElementTimes(t *testing.T) {
+	start := schema.TimeFieldAttr(time.Date(2022, time.June, 3, 23, 0, 0, 0, time.UTC))
+	end := schema.TimeFieldAttr(time.Date(2022, time.June, 4, 6, 11, 0, 0, time.UTC))
+	since := schema.TimeFieldAttr(time.Time(start).In(time.FixedZone("CST", 34200)))
+	occurred := schema.TimeFieldAttr(time.Date(2022, time.June, 4, 4, 52, 0, 0, time.UTC))
+
+	elements := []schema.Element{
+		{Type: "maximum_air_temperature", Unit: "Celsius", Value: "13.4", Instance: "running",
+			StartTimeUTC: &start, EndTimeUTC: &end, TimeUTC: &occurred},
+		{Type: "rainfall", Unit: "mm", Value: "0.2", Duration: 431,
+			StartTimeUTC: &start, EndTimeUTC: &end, StartTimeLocal: &since},
+		{Type: "rainfall_24hr", Unit: "mm", Value: "1.4", Duration: 720},
+		{Type: "air_temperature", Unit: "Celsius", Value: "11.3"},
+	}
+
+	product := schema.Product{
+		Amoc: schema.Amoc{Identifier: "a5a5a5a5"},
+		Observations: &schema.Observations{Station: []schema.Station{
+			{WmoID: "222", BomID: "111", Period: []schema.Period{{Index: "0", TimeUTC: end, Level: []schema.Level{{Index: "0", Element: elements}}}}},
+		}},
+	}
+
+	o := New(&product)
+
+	expected := `
+# HELP bom_observations_rainfall Rainfall.
+# TYPE bom_observations_rainfall gauge
+bom_observations_rainfall{bom_id="222",description="",identifier="a5a5a5a5",index="0",latitude="0.000000",longitude="0.000000",region="",station_name="",type="12hr",units="mm",wmo_id="111"} 1.4 1654323060000
+bom_observations_rainfall{bom_id="222",description="",identifier="a5a5a5a5",index="0",latitude="0.000000",longitude="0.000000",region="",station_name="",type="8:30am",units="mm",wmo_id="111"} 0.2 1654323060000
+# HELP bom_observations_rainfall_window_end_time_seconds End of the rainfall accumulation window, in seconds since the epoch.
+# TYPE bom_observations_rainfall_window_end_time_seconds gauge
+bom_observations_rainfall_window_end_time_seconds{bom_id="222",description="",identifier="a5a5a5a5",index="0",latitude="0.000000",longitude="0.000000",region="",station_name="",type="8:30am",wmo_id="111"} 1.65432306e+09 1654323060000
+# HELP bom_observations_rainfall_window_start_time_seconds Start of the rainfall accumulation window, in seconds since the epoch.
+# TYPE bom_observations_rainfall_window_start_time_seconds gauge
+bom_observations_rainfall_window_start_time_seconds{bom_id="222",description="",identifier="a5a5a5a5",index="0",latitude="0.000000",longitude="0.000000",region="",station_name="",type="8:30am",wmo_id="111"} 1.6542972e+09 1654323060000
+# HELP bom_observations_temperature_time_seconds Time at which the temperature extreme occurred, in seconds since the epoch.
+# TYPE bom_observations_temperature_time_seconds gauge
+bom_observations_temperature_time_seconds{bom_id="222",description="",identifier="a5a5a5a5",index="0",latitude="0.000000",longitude="0.000000",region="",station_name="",type="maximum",wmo_id="111"} 1.65431832e+09 1654323060000
+`
+
+	err := testutil.CollectAndCompare(o, strings.NewReader(expected),
+		"bom_observations_temperature_time_seconds",
+		"bom_observations_rainfall",
+		"bom_observations_rainfall_window_start_time_seconds",
+		"bom_observations_rainfall_window_end_time_seconds")
+	if err != nil {
+		t.Errorf("Unexpected metrics: %s", err)
+	}
+}
+
+func TestStationInfo(t *testing.T) {
+	height := 29.32
+	seaLevel := 0.0
+
+	product := schema.Product{
+		Amoc: schema.Amoc{Identifier: "a5a5a5a5"},
+		Observations: &schema.Observations{Station: []schema.Station{
+			{WmoID: "222", BomID: "111", Timezone: "Australia/Adelaide", Type: "AWS", Height: &height, DistrictID: "SA_PW001",
+				Period: []schema.Period{{Index: "0", WindSrc: "OMD"}}},
+			{WmoID: "444", BomID: "333", Height: &seaLevel},
+			{WmoID: "666", BomID: "555"},
+		}},
+	}
+
+	o := New(&product)
+
+	expected := `
+# HELP bom_observations_station_height_meters Station height above mean sea level in meters.
+# TYPE bom_observations_station_height_meters gauge
+bom_observations_station_height_meters{bom_id="222",description="",identifier="a5a5a5a5",latitude="0.000000",longitude="0.000000",region="",station_name="",wmo_id="111"} 29.32
+bom_observations_station_height_meters{bom_id="444",description="",identifier="a5a5a5a5",latitude="0.000000",longitude="0.000000",region="",station_name="",wmo_id="333"} 0
+# HELP bom_observations_station_info Station metadata, value is always 1. The wind_src label is that of the most recent period.
+# TYPE bom_observations_station_info gauge
+bom_observations_station_info{bom_id="222",description="",forecast_district_id="SA_PW001",identifier="a5a5a5a5",latitude="0.000000",longitude="0.000000",region="",station_name="",station_type="AWS",timezone="Australia/Adelaide",wind_src="OMD",wmo_id="111"} 1
+bom_observations_station_info{bom_id="444",description="",forecast_district_id="",identifier="a5a5a5a5",latitude="0.000000",longitude="0.000000",region="",station_name="",station_type="",timezone="",wind_src="",wmo_id="333"} 1
+bom_observations_station_info{bom_id="666",description="",forecast_district_id="",identifier="a5a5a5a5",latitude="0.000000",longitude="0.000000",region="",station_name="",station_type="",timezone="",wind_src="",wmo_id="555"} 1
+`
+
+	err := testutil.CollectAndCompare(o, strings.NewReader(expected),
+		"bom_observations_station_info",
+		"bom_observations_station_height_meters")
+	if err != nil {
+		t.Errorf("Unexpected metrics: %s", err)
+	}
+}
+
+func TestPeriodsAndLevels(t *testing.T) {
+	t0 := schema.TimeFieldAttr(time.Date(2022, time.June, 4, 6, 0, 0, 0, time.UTC))
+	t1 := schema.TimeFieldAttr(time.Date(2022, time.June, 4, 5, 30, 0, 0, time.UTC))
+	element := func(v string) []schema.Element {
+		return []schema.Element{{Type: "air_temperature", Unit: "Celsius", Value: v}}
+	}
+
+	product := schema.Product{
+		Amoc: schema.Amoc{Identifier: "a5a5a5a5"},
+		Observations: &schema.Observations{Station: []schema.Station{
+			{WmoID: "222", BomID: "111", Period: []schema.Period{
+				{Index: "0", TimeUTC: t0, Level: []schema.Level{
+					{Index: "0", Type: "surface", Element: element("11.1")},
+					{Index: "1", Type: "depth", Element: element("12.2")},
+				}},
+				{Index: "1", TimeUTC: t1, Level: []schema.Level{
+					{Index: "0", Type: "surface", Element: element("13.3")},
+				}},
+			}},
+		}},
+	}
+
+	o := New(&product)
+
+	expected := `
+# HELP bom_observations_temperature Temperature observation.
+# TYPE bom_observations_temperature gauge
+bom_observations_temperature{bom_id="222",description="",identifier="a5a5a5a5",index="0",latitude="0.000000",level="0",longitude="0.000000",region="",station_name="",type="ambient",units="Celsius",wmo_id="111"} 11.1 1654322400000
+bom_observations_temperature{bom_id="222",description="",identifier="a5a5a5a5",index="0",latitude="0.000000",level="1",longitude="0.000000",region="",station_name="",type="ambient",units="Celsius",wmo_id="111"} 12.2 1654322400000
+bom_observations_temperature{bom_id="222",description="",identifier="a5a5a5a5",index="1",latitude="0.000000",level="0",longitude="0.000000",region="",station_name="",type="ambient",units="Celsius",wmo_id="111"} 13.3 1654320600000
+`
+
+	err := testutil.CollectAndCompare(o, strings.NewReader(expected), "bom_observations_temperature")
+	if err != nil {
+		t.Errorf("Unexpected metrics: %s", err)
+	}
+}
+
+func TestBaseUnits(t *testing.T) {
+	elements := []schema.Element{
+		{Type: "air_temperature", Unit: "Celsius", Value: "11.3"},
+		{Type: "wind_spd_kmh", Unit: "km/h", Value: "36"},
+		{Type: "wind_spd", Unit: "knots", Value: "19"},
+		{Type: "wind_gust_spd", Unit: "knots", Value: "10"},
+		{Type: "rel-humidity", Unit: "%", Value: "85"},
+		{Type: "msl_pres", Unit: "hPa", Value: "1013.2"},
+		{Type: "vis_km", Unit: "km", Value: "10"},
+		{Type: "cloud_oktas", Value: "7"},
+		{Type: "rainfall", Unit: "mm", Value: "1.2"},
+	}
+
+	product := schema.Product{
+		Amoc: schema.Amoc{Identifier: "a5a5a5a5"},
+		Observations: &schema.Observations{Station: []schema.Station{
+			{WmoID: "222", BomID: "111", Period: []schema.Period{{Index: "0", Level: []schema.Level{{Index: "0", Element: elements}}}}},
+		}},
+	}
+
+	o := NewWithBaseUnits(&product)
+
+	labels := `bom_id="222",description="",identifier="a5a5a5a5",index="0",latitude="0.000000",longitude="0.000000",region="",station_name="",wmo_id="111"`
+	typed := func(t string) string {
+		return strings.Replace(labels, `,wmo_id`, `,type="`+t+`",wmo_id`, 1)
+	}
+	expected := `
+# HELP bom_observations_cloud_cover_oktas Cloud cover.
+# TYPE bom_observations_cloud_cover_oktas gauge
+bom_observations_cloud_cover_oktas{` + labels + `} 7
+# HELP bom_observations_humidity_ratio Relative humidity.
+# TYPE bom_observations_humidity_ratio gauge
+bom_observations_humidity_ratio{` + labels + `} 0.85
+# HELP bom_observations_pressure_pascals Atmospheric pressure.
+# TYPE bom_observations_pressure_pascals gauge
+bom_observations_pressure_pascals{` + typed("msl") + `} 101320
+# HELP bom_observations_rainfall_meters Rainfall.
+# TYPE bom_observations_rainfall_meters gauge
+bom_observations_rainfall_meters{` + typed("9am") + `} 0.0012
+# HELP bom_observations_temperature_celsius Temperature observation.
+# TYPE bom_observations_temperature_celsius gauge
+bom_observations_temperature_celsius{` + typed("ambient") + `} 11.3
+# HELP bom_observations_visibility_meters Visibility.
+# TYPE bom_observations_visibility_meters gauge
+bom_observations_visibility_meters{` + labels + `} 10000
+# HELP bom_observations_wind_speed_meters_per_second Wind speed.
+# TYPE bom_observations_wind_speed_meters_per_second gauge
+bom_observations_wind_speed_meters_per_second{` + typed("average") + `} 10
+bom_observations_wind_speed_meters_per_second{` + typed("gust") + `} 5.144444444444445
+`
+
+	err := testutil.CollectAndCompare(o, strings.NewReader(expected),
+		"bom_observations_temperature_celsius",
+		"bom_observations_wind_speed_meters_per_second",
+		"bom_observations_humidity_ratio",
+		"bom_observations_pressure_pascals",
+		"bom_observations_visibility_meters",
+		"bom_observations_cloud_base_meters",
+		"bom_observations_cloud_cover_oktas",
+		"bom_observations_wind_direction_degrees",
+		"bom_observations_rainfall_meters")
+	if err != nil {
+		t.Errorf("Unexpected metrics: %s", err)
+	}
+
+	problems, err := testutil.CollectAndLint(o, BaseUnitMetricNames...)
+	if err != nil {
+		t.Errorf("CollectAndLint failed: %v", err)
+	}
+	if len(problems) > 0 {
+		t.Errorf("Problems found: %v", problems)
+	}
+}
+
+func TestCategories(t *testing.T) {
+	elements := []schema.Element{
+		{Type: "weather", Value: "Fine"},
+		{Type: "cloud", Value: "Partly cloudy"},
+		{Type: "cloud_type_id", Value: "35"},
+		{Type: "cloud_type_id", Value: "77"},
+		{Type: "wind_dir", Value: "NNE"},
+		{Type: "maximum_gust_dir", Value: "SW"},
+		{Type: "wind_dir", Value: "UP"},
+	}
+
+	product := schema.Product{
+		Amoc: schema.Amoc{Identifier: "a5a5a5a5"},
+		Observations: &schema.Observations{Station: []schema.Station{
+			{WmoID: "222", BomID: "111", Period: []schema.Period{{Index: "0", Level: []schema.Level{{Index: "0", Element: elements}}}}},
+		}},
+	}
+
+	expected := `
+# HELP bom_observations_cloud_info Sky condition, value is always 1. The condition label holds the sky observed (eg. 'Partly cloudy').
+# TYPE bom_observations_cloud_info gauge
+bom_observations_cloud_info{bom_id="222",condition="Partly cloudy",description="",identifier="a5a5a5a5",index="0",latitude="0.000000",longitude="0.000000",region="",station_name="",wmo_id="111"} 1
+# HELP bom_observations_cloud_type Cloud type, value is the cloud_type_id code with the genus label decoded from it.
+# TYPE bom_observations_cloud_type gauge
+bom_observations_cloud_type{bom_id="222",cloud_type="Stratocumulus other than cumulogenitus",description="",genus="Stratocumulus",identifier="a5a5a5a5",index="0",latitude="0.000000",longitude="0.000000",region="",station_name="",wmo_id="111"} 35
+# HELP bom_observations_weather_info Present weather, value is always 1. The condition label holds the weather observed (eg. 'Fine').
+# TYPE bom_observations_weather_info gauge
+bom_observations_weather_info{bom_id="222",condition="Fine",description="",identifier="a5a5a5a5",index="0",latitude="0.000000",longitude="0.000000",region="",station_name="",wmo_id="111"} 1
+# HELP bom_observations_wind_compass Average wind direction as a compass point, in degrees.
+# TYPE bom_observations_wind_compass gauge
+bom_observations_wind_compass{bom_id="222",description="",direction="NNE",identifier="a5a5a5a5",index="0",latitude="0.000000",longitude="0.000000",region="",station_name="",units="deg",wmo_id="111"} 22.5
+`
+
+	err := testutil.CollectAndCompare(New(&product), strings.NewReader(expected),
+		"bom_observations_weather_info",
+		"bom_observations_cloud_info",
+		"bom_observations_cloud_type",
+		"bom_observations_wind_compass")
+	if err != nil {
+		t.Errorf("Unexpected metrics: %s", err)
+	}
+
+	expected = `
+# HELP bom_observations_wind_compass_degrees Average wind direction as a compass point, in degrees.
+# TYPE bom_observations_wind_compass_degrees gauge
+bom_observations_wind_compass_degrees{bom_id="222",description="",direction="NNE",identifier="a5a5a5a5",index="0",latitude="0.000000",longitude="0.000000",region="",station_name="",wmo_id="111"} 22.5
+`
+
+	err = testutil.CollectAndCompare(NewWithBaseUnits(&product), strings.NewReader(expected), "bom_observations_wind_compass_degrees")
+	if err != nil {
+		t.Errorf("Unexpected metrics: %s", err)
+	}
+
+	for point, degrees := range map[string]float64{"N": 360, "ENE": 67.5, "s": 180, "NNW": 337.5, "CALM": 0} {
+		d, ok := CompassDegrees(point)
+		if !ok || d != degrees {
+			t.Errorf("'%s' is %v (%v), expected %v", point, d, ok, degrees)
+		}
+	}
+	if _, ok := ParseCloudType("x"); ok {
+		t.Errorf("Expected invalid cloud_type_id to fail")
+	}
+}
+
+func TestMaxWindGust(t *testing.T) {
+	occurred := schema.TimeFieldAttr(time.Date(2022, time.May, 21, 23, 41, 0, 0, time.UTC))
+
+	elements := []schema.Element{
+		{Type: "maximum_gust_spd", Unit: "knots", Value: "11", Instance: "running", TimeUTC: &occurred},
+		{Type: "maximum_gust_kmh", Unit: "km/h", Value: "20", Instance: "running", TimeUTC: &occurred},
+		{Type: "maximum_gust_dir", Value: "NE", Instance: "running", TimeUTC: &occurred},
+	}
+
+	product := schema.Product{
+		Amoc: schema.Amoc{Identifier: "a5a5a5a5"},
+		Observations: &schema.Observations{Station: []schema.Station{
+			{WmoID: "222", BomID: "111", Period: []schema.Period{{Index: "0", Level: []schema.Level{{Index: "0", Element: elements}}}}},
+		}},
+	}
+
+	expected := `
+# HELP bom_observations_max_wind_gust Maximum wind gust of the day.
+# TYPE bom_observations_max_wind_gust gauge
+bom_observations_max_wind_gust{bom_id="222",description="",direction="NE",identifier="a5a5a5a5",index="0",latitude="0.000000",longitude="0.000000",region="",station_name="",units="km/h",wmo_id="111"} 20
+bom_observations_max_wind_gust{bom_id="222",description="",direction="NE",identifier="a5a5a5a5",index="0",latitude="0.000000",longitude="0.000000",region="",station_name="",units="knots",wmo_id="111"} 11
+# HELP bom_observations_max_wind_gust_time_seconds Time at which the maximum wind gust of the day occurred, in seconds since the epoch.
+# TYPE bom_observations_max_wind_gust_time_seconds gauge
+bom_observations_max_wind_gust_time_seconds{bom_id="222",description="",identifier="a5a5a5a5",index="0",latitude="0.000000",longitude="0.000000",region="",station_name="",wmo_id="111"} 1.65317646e+09
+`
+
+	err := testutil.CollectAndCompare(New(&product), strings.NewReader(expected),
+		"bom_observations_max_wind_gust",
+		"bom_observations_max_wind_gust_time_seconds")
+	if err != nil {
+		t.Errorf("Unexpected metrics: %s", err)
+	}
+
+	expected = `
+# HELP bom_observations_max_wind_gust_meters_per_second Maximum wind gust of the day.
+# TYPE bom_observations_max_wind_gust_meters_per_second gauge
+bom_observations_max_wind_gust_meters_per_second{bom_id="222",description="",direction="NE",identifier="a5a5a5a5",index="0",latitude="0.000000",longitude="0.000000",region="",station_name="",wmo_id="111"} 5.555555555555555
+`
+
+	err = testutil.CollectAndCompare(NewWithBaseUnits(&product), strings.NewReader(expected), "bom_observations_max_wind_gust_meters_per_second")
+	if err != nil {
+		t.Errorf("Unexpected metrics: %s", err)
+	}
+}
+
+func TestUnitsLabel(t *testing.T) {
+	elements := []schema.Element{
+		{Type: "wind_spd", Unit: "Knots", Value: "19"},
+	}
+
+	product := schema.Product{
+		Amoc: schema.Amoc{Identifier: "a5a5a5a5"},
+		Observations: &schema.Observations{Station: []schema.Station{
+			{WmoID: "222", BomID: "111", Period: []schema.Period{{Index: "0", Level: []schema.Level{{Index: "0", Element: elements}}}}},
+		}},
+	}
+
+	// Units are labelled as spelt in the product, even if not recognised.
+	expected := `
+# HELP bom_observations_wind_speed Wind speed.
+# TYPE bom_observations_wind_speed gauge
+bom_observations_wind_speed{bom_id="222",description="",identifier="a5a5a5a5",index="0",latitude="0.000000",longitude="0.000000",region="",station_name="",type="average",units="Knots",wmo_id="111"} 19
+`
+
+	err := testutil.CollectAndCompare(New(&product), strings.NewReader(expected), "bom_observations_wind_speed")
+	if err != nil {
+		t.Errorf("Unexpected metrics: %s", err)
+	}
+
+	// Without a recognised unit there is nothing to convert.
+	count := testutil.CollectAndCount(NewWithBaseUnits(&product), "bom_observations_wind_speed_meters_per_second")
+	if count != 0 {
+		t.Errorf("Got %d metrics, expected 0", count)
+	}
+}
@@ -0,0 +1,177 @@
+package offline
+
+import (
+	"fmt"
+	"github.com/fsnotify/fsnotify"
+	"github.com/gkoh/bom_exporter/bom"
+	"github.com/gkoh/bom_exporter/bom/connection"
+	"github.com/gkoh/bom_exporter/bom/connection/file"
+	"github.com/gkoh/bom_exporter/bom/schema"
+	log "github.com/sirupsen/logrus"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Store holds the products found in a local directory, reloading each one as
+// its file changes.
+type Store struct {
+	sync.RWMutex
+	dir       string
+	newMetric func(connection.Retriever) *bom.Metric
+	products  map[string]*bom.Metric
+	failed    map[string]error
+	watcher   *fsnotify.Watcher
+}
+
+// New loads every product in dir, parsed with the given schema validation
+// mode, and starts watching it for changes.
+func New(dir string, mode schema.Mode) (*Store, error) {
+	return NewWithFunc(dir, func(r connection.Retriever) *bom.Metric {
+		return bom.NewWithMode(r, mode)
+	})
+}
+
+// NewStreaming loads every product in dir as streaming metrics, see
+// bom.NewStreaming, and starts watching it for changes.
+func NewStreaming(dir string) (*Store, error) {
+	return NewWithFunc(dir, bom.NewStreaming)
+}
+
+// NewWithFunc loads every product in dir into the Metric created for it by
+// newMetric, and starts watching it for changes.
+func NewWithFunc(dir string, newMetric func(connection.Retriever) *bom.Metric) (*Store, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	err = watcher.Add(dir)
+	if err != nil {
+		watcher.Close()
+		return nil, err
+	}
+
+	s := &Store{dir: dir, newMetric: newMetric, products: make(map[string]*bom.Metric), failed: make(map[string]error), watcher: watcher}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		watcher.Close()
+		return nil, err
+	}
+	for _, e := range entries {
+		if id, ok := productID(e.Name()); ok && !e.IsDir() {
+			s.load(id)
+		}
+	}
+
+	go s.watch()
+
+	return s, nil
+}
+
+// productID returns the product identifier for an XML file name.
+func productID(name string) (string, bool) {
+	if filepath.Ext(name) != ".xml" {
+		return "", false
+	}
+
+	id := strings.TrimSuffix(filepath.Base(name), ".xml")
+	return id, file.ValidID(id)
+}
+
+// load parses the product file for id, keeping any previously loaded version
+// if the file cannot be parsed (eg. it is still being written).
+func (s *Store) load(id string) {
+	c, err := file.NewInDir(s.dir, id)
+	if err != nil {
+		log.Warnf("Ignoring '%s': %s", id, err)
+		return
+	}
+
+	m := s.newMetric(c)
+	err = m.RetrieveAndParse()
+	if err != nil {
+		log.Warnf("Failed to load '%s': %s", c.Identifier(), err)
+		s.Lock()
+		s.failed[id] = err
+		s.Unlock()
+		return
+	}
+
+	// A streaming product is opened again when collected, rather than
+	// holding its file open until then.
+	m.Close()
+
+	s.Lock()
+	s.products[id] = m
+	delete(s.failed, id)
+	s.Unlock()
+
+	log.Infof("Loaded '%s'", c.Identifier())
+}
+
+func (s *Store) remove(id string) {
+	s.Lock()
+	delete(s.products, id)
+	delete(s.failed, id)
+	s.Unlock()
+
+	log.Infof("Removed '%s'", id)
+}
+
+func (s *Store) watch() {
+	for {
+		select {
+		case ev, ok := <-s.watcher.Events:
+			if !ok {
+				return
+			}
+
+			id, ok := productID(ev.Name)
+			if !ok {
+				continue
+			}
+
+			if ev.Has(fsnotify.Create) || ev.Has(fsnotify.Write) {
+				s.load(id)
+			} else if ev.Has(fsnotify.Remove) || ev.Has(fsnotify.Rename) {
+				s.remove(id)
+			}
+
+		case err, ok := <-s.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Warnf("Watching '%s' failed: %s", s.dir, err)
+		}
+	}
+}
+
+// Get returns the loaded product for id. If the product has never loaded
+// successfully the error wraps the reason it failed.
+func (s *Store) Get(id string) (*bom.Metric, error) {
+	if !file.ValidID(id) {
+		return nil, fmt.Errorf("Invalid product identifier '%s'", id)
+	}
+
+	s.RLock()
+	defer s.RUnlock()
+
+	m, ok := s.products[id]
+	if !ok {
+		err := fmt.Errorf("Product '%s' not loaded from '%s'", id, s.dir)
+		if failed, ok := s.failed[id]; ok {
+			err = fmt.Errorf("Product '%s' failed to load: %w", id, failed)
+		}
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// Close stops watching the directory.
+func (s *Store) Close() error {
+	return s.watcher.Close()
+}
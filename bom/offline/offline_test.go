@@ -0,0 +1,101 @@
+package offline
+
+import (
+	"errors"
+	"github.com/gkoh/bom_exporter/bom/schema"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func copyFixture(t *testing.T, src string, dst string) {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		t.Fatalf("Failed to read '%s': %s", src, err)
+	}
+
+	// Write via rename so the watcher sees a single complete file.
+	tmp := dst + ".tmp"
+	err = os.WriteFile(tmp, data, 0644)
+	if err != nil {
+		t.Fatalf("Failed to write '%s': %s", tmp, err)
+	}
+	err = os.Rename(tmp, dst)
+	if err != nil {
+		t.Fatalf("Failed to rename '%s': %s", tmp, err)
+	}
+}
+
+// eventually polls f until it returns true or a deadline passes.
+func eventually(f func() bool) bool {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if f() {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return false
+}
+
+func TestStore(t *testing.T) {
+	dir := t.TempDir()
+	copyFixture(t, "../schema/IDS60920.xml", filepath.Join(dir, "IDS60920.xml"))
+	err := os.WriteFile(filepath.Join(dir, "README"), []byte("not a product"), 0644)
+	if err != nil {
+		t.Fatalf("Failed to write file: %s", err)
+	}
+
+	s, err := New(dir, schema.Strict)
+	if err != nil {
+		t.Fatalf("Failed to create store: %s", err)
+	}
+	defer s.Close()
+
+	first, err := s.Get("IDS60920")
+	if err != nil {
+		t.Fatalf("Failed to get initial product: %s", err)
+	}
+
+	for _, id := range []string{"../IDS60920", "IDS60920.xml", "README", "IDS10034"} {
+		if _, err := s.Get(id); err == nil {
+			t.Errorf("Expected error getting '%s'", id)
+		}
+	}
+
+	// New files are loaded.
+	copyFixture(t, "../schema/IDS10034.xml", filepath.Join(dir, "IDS10034.xml"))
+	if !eventually(func() bool { _, err := s.Get("IDS10034"); return err == nil }) {
+		t.Errorf("New product was not loaded")
+	}
+
+	// Changed files are reloaded.
+	copyFixture(t, "../schema/IDS60920.xml", filepath.Join(dir, "IDS60920.xml"))
+	if !eventually(func() bool { m, _ := s.Get("IDS60920"); return m != nil && m != first }) {
+		t.Errorf("Changed product was not reloaded")
+	}
+
+	// Removed files are dropped.
+	os.Remove(filepath.Join(dir, "IDS10034.xml"))
+	if !eventually(func() bool { _, err := s.Get("IDS10034"); return err != nil }) {
+		t.Errorf("Removed product is still loaded")
+	}
+}
+
+func TestStoreStrict(t *testing.T) {
+	dir := t.TempDir()
+	copyFixture(t, "../connection/test.xml", filepath.Join(dir, "test.xml"))
+
+	s, err := New(dir, schema.Strict)
+	if err != nil {
+		t.Fatalf("Failed to create store: %s", err)
+	}
+	defer s.Close()
+
+	_, err = s.Get("test")
+	var ve *schema.ValidationError
+	if !errors.As(err, &ve) {
+		t.Errorf("Expected ValidationError, got %v", err)
+	}
+}
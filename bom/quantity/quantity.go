@@ -0,0 +1,29 @@
+package quantity
+
+import (
+	"github.com/gkoh/bom_exporter/bom/schema"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Desc describes a metric for a physical quantity. With base units values are
+// converted to Unit, otherwise they are exported as found with a units label.
+type Desc struct {
+	*prometheus.Desc
+	Unit schema.Unit
+}
+
+// NewDesc creates the description of the quantity metric bom_<subsystem>_<name>.
+// With base units the metric is named with suffix, eg. _meters, otherwise a
+// trailing units label is added to labels.
+func NewDesc(subsystem string, name string, help string, unit schema.Unit, suffix string, baseUnits bool, labels []string, constLabels prometheus.Labels) Desc {
+	labels = append([]string{}, labels...)
+	if baseUnits {
+		name = name + "_" + suffix
+	} else {
+		labels = append(labels, "units")
+	}
+
+	return Desc{
+		Desc: prometheus.NewDesc(prometheus.BuildFQName("bom", subsystem, name), help, labels, constLabels),
+		Unit: unit}
+}
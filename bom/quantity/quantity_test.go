@@ -0,0 +1,31 @@
+package quantity
+
+import (
+	"github.com/gkoh/bom_exporter/bom/schema"
+	"github.com/prometheus/client_golang/prometheus"
+	"strings"
+	"testing"
+)
+
+func TestNewDesc(t *testing.T) {
+	labels := []string{"aac", "bound"}
+	constLabels := prometheus.Labels{"identifier": "a5a5a5a5"}
+
+	inputs := []struct {
+		baseUnits bool
+		expected  string
+	}{
+		{false, `fqName: "bom_marine_sea_height", help: "Sea height.", constLabels: {identifier="a5a5a5a5"}, variableLabels: {aac,bound,units}`},
+		{true, `fqName: "bom_marine_sea_height_meters", help: "Sea height.", constLabels: {identifier="a5a5a5a5"}, variableLabels: {aac,bound}`},
+	}
+
+	for _, x := range inputs {
+		d := NewDesc("marine", "sea_height", "Sea height.", schema.UnitMeters, "meters", x.baseUnits, labels, constLabels)
+		if !strings.Contains(d.String(), x.expected) {
+			t.Errorf("Got %s, expected %s", d, x.expected)
+		}
+		if d.Unit != schema.UnitMeters {
+			t.Errorf("Got unit %s, expected %s", d.Unit, schema.UnitMeters)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Alert contains an unmarshalled Common Alerting Protocol (CAP) 1.2 alert.
+type Alert struct {
+	XMLName    xml.Name  `xml:"alert" json:"-"`
+	Identifier string    `xml:"identifier" json:"identifier,omitempty"`
+	Sender     string    `xml:"sender" json:"sender,omitempty"`
+	Sent       time.Time `xml:"sent" json:"sent"`
+	Status     string    `xml:"status" json:"status,omitempty"`
+	MsgType    string    `xml:"msgType" json:"msg_type,omitempty"`
+	Scope      string    `xml:"scope" json:"scope,omitempty"`
+	References string    `xml:"references" json:"references,omitempty"`
+	Info       []Info    `xml:"info" json:"info,omitempty"`
+}
+
+// Info contains an unmarshalled CAP info block.
+type Info struct {
+	Language    string      `xml:"language" json:"language,omitempty"`
+	Category    []string    `xml:"category" json:"category,omitempty"`
+	Event       string      `xml:"event" json:"event,omitempty"`
+	Urgency     string      `xml:"urgency" json:"urgency,omitempty"`
+	Severity    string      `xml:"severity" json:"severity,omitempty"`
+	Certainty   string      `xml:"certainty" json:"certainty,omitempty"`
+	Effective   *time.Time  `xml:"effective" json:"effective,omitempty"`
+	Onset       *time.Time  `xml:"onset" json:"onset,omitempty"`
+	Expires     *time.Time  `xml:"expires" json:"expires,omitempty"`
+	SenderName  string      `xml:"senderName" json:"sender_name,omitempty"`
+	Headline    string      `xml:"headline" json:"headline,omitempty"`
+	Description string      `xml:"description" json:"description,omitempty"`
+	Area        []AlertArea `xml:"area" json:"area,omitempty"`
+}
+
+// ActiveAt reports whether the info is in force at t, that is after any onset
+// (or effective time) and before any expiry.
+func (i *Info) ActiveAt(t time.Time) bool {
+	start := i.Onset
+	if start == nil {
+		start = i.Effective
+	}
+	if start != nil && t.Before(*start) {
+		return false
+	}
+
+	return i.Expires == nil || t.Before(*i.Expires)
+}
+
+// AlertArea contains an unmarshalled CAP area block.
+type AlertArea struct {
+	Description string    `xml:"areaDesc" json:"area_desc,omitempty"`
+	Polygons    []Polygon `xml:"polygon" json:"polygon,omitempty"`
+	Geocodes    []Geocode `xml:"geocode" json:"geocode,omitempty"`
+}
+
+// Geocode returns the first geocode value with the given name, or "".
+func (a *AlertArea) Geocode(name string) string {
+	for _, g := range a.Geocodes {
+		if g.Name == name {
+			return g.Value
+		}
+	}
+	return ""
+}
+
+// Contains reports whether any polygon of the area contains the point.
+func (a *AlertArea) Contains(lat float64, lon float64) bool {
+	for _, p := range a.Polygons {
+		if p.Contains(lat, lon) {
+			return true
+		}
+	}
+	return false
+}
+
+// Geocode contains an unmarshalled CAP geocode, eg. the AMOC area code.
+type Geocode struct {
+	Name  string `xml:"valueName" json:"value_name"`
+	Value string `xml:"value" json:"value"`
+}
+
+// Point is a WGS 84 latitude and longitude.
+type Point struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// Polygon is a closed ring of points, given in CAP as space separated
+// "lat,lon" pairs.
+type Polygon []Point
+
+// UnmarshalXML decodes a CAP polygon.
+func (p *Polygon) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var s string
+	err := d.DecodeElement(&s, &start)
+	if err != nil {
+		return err
+	}
+
+	for _, pair := range strings.Fields(s) {
+		lat, lon, ok := strings.Cut(pair, ",")
+		if !ok {
+			return fmt.Errorf("Invalid polygon point '%s'", pair)
+		}
+
+		var pt Point
+		pt.Latitude, err = strconv.ParseFloat(lat, 64)
+		if err != nil {
+			return err
+		}
+		pt.Longitude, err = strconv.ParseFloat(lon, 64)
+		if err != nil {
+			return err
+		}
+		*p = append(*p, pt)
+	}
+
+	return nil
+}
+
+// Contains reports whether the point lies inside the polygon, by ray casting.
+func (p Polygon) Contains(lat float64, lon float64) bool {
+	inside := false
+	for i, j := 0, len(p)-1; i < len(p); j, i = i, i+1 {
+		a, b := p[i], p[j]
+		if (a.Latitude > lat) != (b.Latitude > lat) &&
+			lon < (b.Longitude-a.Longitude)*(lat-a.Latitude)/(b.Latitude-a.Latitude)+a.Longitude {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// Parse unmarshals a CAP alert.
+func (a *Alert) Parse(data []byte) error {
+	return xml.Unmarshal(data, a)
+}
+
+// RootElement returns the name of the root element of an XML document, eg.
+// "product" for BoM products or "alert" for CAP alerts.
+func RootElement(data []byte) (string, error) {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return "", fmt.Errorf("No root element")
+		}
+		if err != nil {
+			return "", err
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			return start.Name.Local, nil
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package schema
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+// Handler receives the parts of a product as they are read by Decode.
+//
+// Header is called once, before any area or station, with the product
+// attributes and AMOC decoded. Its Forecast or Observations field is set, but
+// empty, to indicate the product type. Warnings and tide predictions are
+// small, so Warning or Tides is decoded in full before Header is called. Area
+// and Station are then called for each area or station in document order.
+type Handler interface {
+	Header(p *Product) error
+	Area(a *Area) error
+	Station(s *Station) error
+}
+
+// Decode reads a product from r, passing each area or station to h as soon as
+// it has been decoded rather than building the whole Product in memory. An
+// error returned by h stops decoding.
+func Decode(r io.Reader, h Handler) error {
+	return NewDecoder(r, Lenient).Decode(h)
+}
+
+// A Decoder reads a product from a stream in two steps, first its header and
+// then each area or station in turn, validating it according to its mode as
+// it goes.
+type Decoder struct {
+	d      *xml.Decoder
+	mode   Mode
+	p      Product
+	alert  *Alert
+	header bool
+	next   *xml.StartElement
+	count  int
+}
+
+// NewDecoder creates a Decoder reading from r with the given schema validation
+// mode.
+func NewDecoder(r io.Reader, mode Mode) *Decoder {
+	return &Decoder{d: xml.NewDecoder(r), mode: mode}
+}
+
+// Header reads the product up to its first area or station, returning the
+// product as passed to Handler.Header. A CAP alert is read in full, returning
+// a nil product, and is then available from Alert. In Strict mode a
+// *ValidationError is returned for a product with problems in its header, or
+// in its content if that has already been read.
+func (d *Decoder) Header() (*Product, error) {
+	if d.header {
+		if d.alert != nil {
+			return nil, nil
+		}
+		return &d.p, nil
+	}
+	d.header = true
+
+	root := true
+	for d.p.Forecast == nil && d.p.Observations == nil && d.p.Warning == nil && d.p.Tides == nil && d.next == nil {
+		tok, err := d.d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		if root {
+			root = false
+			switch start.Name.Local {
+			case "product":
+				for _, a := range start.Attr {
+					switch a.Name.Local {
+					case "version":
+						d.p.Version = a.Value
+					case "noNamespaceSchemaLocation":
+						d.p.SchemaLocation = a.Value
+					}
+				}
+				continue
+			case "alert":
+				a := &Alert{}
+				err = d.d.DecodeElement(a, &start)
+				if err != nil {
+					return nil, err
+				}
+				d.alert = a
+				return nil, nil
+			default:
+				return nil, fmt.Errorf("Expected element <product> but have <%s>", start.Name.Local)
+			}
+		}
+
+		switch start.Name.Local {
+		case "amoc":
+			err = d.d.DecodeElement(&d.p.Amoc, &start)
+		case "forecast":
+			d.p.Forecast = &Forecast{}
+		case "observations":
+			d.p.Observations = &Observations{}
+		case "warning":
+			d.p.Warning = &Warning{}
+			err = d.d.DecodeElement(d.p.Warning, &start)
+		case "tides":
+			d.p.Tides = &Tides{}
+			err = d.d.DecodeElement(d.p.Tides, &start)
+		case "area", "station":
+			d.next = &start
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+	if root {
+		return nil, fmt.Errorf("No root element")
+	}
+
+	d.p.detectVersion()
+
+	if d.mode == Strict {
+		problems := d.p.headerProblems()
+		if d.p.Forecast == nil && d.p.Observations == nil {
+			problems = append(problems, d.p.contentProblems(d.p.contentCount())...)
+		}
+		err := d.p.validationError(problems)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &d.p, nil
+}
+
+// Alert returns the CAP alert read by Header, or nil if the stream holds a
+// BoM product.
+func (d *Decoder) Alert() *Alert {
+	return d.alert
+}
+
+// Decode reads the rest of the product, reading the header first if Header
+// has not been called, and passes it to h as for the Decode function. In
+// Strict mode a *ValidationError is returned once the whole product has been
+// read if it has no areas or stations.
+func (d *Decoder) Decode(h Handler) error {
+	p, err := d.Header()
+	if err != nil {
+		return err
+	}
+	if p == nil {
+		return fmt.Errorf("Expected element <product> but have <alert>")
+	}
+
+	err = h.Header(p)
+	if err != nil {
+		return err
+	}
+
+	if d.next != nil {
+		err = d.content(d.next, h)
+		d.next = nil
+		if err != nil {
+			return err
+		}
+	}
+
+	for {
+		tok, err := d.d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if start, ok := tok.(xml.StartElement); ok {
+			err = d.content(&start, h)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if d.mode == Strict && (p.Forecast != nil || p.Observations != nil) {
+		return p.validationError(p.contentProblems(d.count))
+	}
+
+	return nil
+}
+
+// content decodes the area or station starting at start, passing it to h.
+func (d *Decoder) content(start *xml.StartElement, h Handler) error {
+	switch start.Name.Local {
+	case "area":
+		var a Area
+		err := d.d.DecodeElement(&a, start)
+		if err != nil {
+			return err
+		}
+		d.count++
+		return h.Area(&a)
+	case "station":
+		var s Station
+		err := d.d.DecodeElement(&s, start)
+		if err != nil {
+			return err
+		}
+		d.count++
+		return h.Station(&s)
+	}
+	return nil
+}
@@ -1,7 +1,20 @@
 package schema
 
 import (
+	"encoding/json"
+	"fmt"
+	"github.com/goccy/go-yaml"
 	log "github.com/sirupsen/logrus"
+	"io"
+)
+
+// Format is an encoding supported by Product.Encode.
+type Format string
+
+// Supported encodings for Product.Encode.
+const (
+	FormatJSON Format = "json"
+	FormatYAML Format = "yaml"
 )
 
 // DumpElements iterates and logs the specified Element array.
@@ -24,3 +37,28 @@ func DumpPeriod(period ForecastPeriod) {
 	DumpElements(period.Elements)
 	DumpTexts(period.Texts)
 }
+
+// Encode writes the product to w in the given format. Timestamps are RFC3339
+// and numeric element values are encoded as numbers. Fields are written in a
+// fixed order, so the output is stable across runs.
+func (p *Product) Encode(w io.Writer, format Format) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case FormatJSON:
+		data = append(data, '\n')
+	case FormatYAML:
+		data, err = yaml.JSONToYAML(data)
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("Unknown format '%s'", format)
+	}
+
+	_, err = w.Write(data)
+	return err
+}
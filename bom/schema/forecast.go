@@ -2,32 +2,46 @@ package schema
 
 import (
 	"encoding/xml"
+	"slices"
 )
 
 // Forecast contains the unmarshalled forecast XML data.
 type Forecast struct {
-	XMLName xml.Name `xml:"forecast"`
-	Area    []Area   `xml:"area"`
+	XMLName xml.Name `xml:"forecast" json:"-"`
+	Area    []Area   `xml:"area" json:"area,omitempty"`
 }
 
 // Area contains the unmarshalled Area XML data.
 type Area struct {
-	XMLName     xml.Name         `xml:"area"`
-	Aac         string           `xml:"aac,attr"`
-	Description string           `xml:"description,attr"`
-	Type        string           `xml:"type,attr"`
-	ParentAac   string           `xml:"parent-aac,attr"`
-	Period      []ForecastPeriod `xml:"forecast-period"`
+	XMLName     xml.Name         `xml:"area" json:"-"`
+	Aac         string           `xml:"aac,attr" json:"aac,omitempty"`
+	Description string           `xml:"description,attr" json:"description,omitempty"`
+	Type        string           `xml:"type,attr" json:"type,omitempty"`
+	ParentAac   string           `xml:"parent-aac,attr" json:"parent_aac,omitempty"`
+	Period      []ForecastPeriod `xml:"forecast-period" json:"forecast_period,omitempty"`
+}
+
+// MarineAreaTypes is the list of area types of coastal and marine forecasts.
+// These areas are decoded by the marine collector, all others by the forecast
+// collector.
+var MarineAreaTypes = []string{
+	"coast",
+	"marine",
+}
+
+// Marine reports whether the area is a coastal or marine forecast area.
+func (a *Area) Marine() bool {
+	return slices.Contains(MarineAreaTypes, a.Type)
 }
 
 // ForecastPeriod contains the unmarshalled forecast period XML data.
 type ForecastPeriod struct {
-	XMLName        xml.Name      `xml:"forecast-period"`
-	Index          string        `xml:"index,attr"`
-	StartTimeLocal TimeFieldAttr `xml:"start-time-local,attr"`
-	EndTimeLocal   TimeFieldAttr `xml:"end-time-local,attr"`
-	StartTimeUTC   TimeFieldAttr `xml:"start-time-utc,attr"`
-	EndTimeUTC     TimeFieldAttr `xml:"end-time-utc,attr"`
-	Elements       []Element     `xml:"element"`
-	Texts          []Text        `xml:"text"`
+	XMLName        xml.Name      `xml:"forecast-period" json:"-"`
+	Index          string        `xml:"index,attr" json:"index,omitempty"`
+	StartTimeLocal TimeFieldAttr `xml:"start-time-local,attr" json:"start_time_local"`
+	EndTimeLocal   TimeFieldAttr `xml:"end-time-local,attr" json:"end_time_local"`
+	StartTimeUTC   TimeFieldAttr `xml:"start-time-utc,attr" json:"start_time_utc"`
+	EndTimeUTC     TimeFieldAttr `xml:"end-time-utc,attr" json:"end_time_utc"`
+	Elements       []Element     `xml:"element" json:"element,omitempty"`
+	Texts          []Text        `xml:"text" json:"text,omitempty"`
 }
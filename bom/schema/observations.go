@@ -6,37 +6,41 @@ import (
 
 // Observations contains the unmarshalled observations XML data.
 type Observations struct {
-	XMLName xml.Name  `xml:"observations"`
-	Station []Station `xml:"station"`
+	XMLName xml.Name  `xml:"observations" json:"-"`
+	Station []Station `xml:"station" json:"station,omitempty"`
 }
 
-// Station contains the unmarshalled station XML data.
+// Station contains the unmarshalled station XML data. Height is nil if the
+// station has no stn-height attribute.
 type Station struct {
-	XMLName     xml.Name `xml:"station"`
-	WmoID       string   `xml:"wmo-id,attr"`
-	BomID       string   `xml:"bom-id,attr"`
-	Timezone    string   `xml:"tz,attr"`
-	Name        string   `xml:"stn-name,attr"`
-	Height      float32  `xml:"stn-height,attr"`
-	Type        string   `xml:"type,attr"`
-	Latitude    float32  `xml:"lat,attr"`
-	Longitude   float32  `xml:"lon,attr"`
-	Description string   `xml:"description,attr"`
-	Period      Period   `xml:"period"`
+	XMLName     xml.Name `xml:"station" json:"-"`
+	WmoID       string   `xml:"wmo-id,attr" json:"wmo_id,omitempty"`
+	BomID       string   `xml:"bom-id,attr" json:"bom_id,omitempty"`
+	Timezone    string   `xml:"tz,attr" json:"tz,omitempty"`
+	Name        string   `xml:"stn-name,attr" json:"stn_name,omitempty"`
+	Height      *float64 `xml:"stn-height,attr" json:"stn_height,omitempty"`
+	Type        string   `xml:"type,attr" json:"type,omitempty"`
+	Latitude    float32  `xml:"lat,attr" json:"lat"`
+	Longitude   float32  `xml:"lon,attr" json:"lon"`
+	Description string   `xml:"description,attr" json:"description,omitempty"`
+	DistrictID  string   `xml:"forecast-district-id,attr" json:"forecast_district_id,omitempty"`
+	Period      []Period `xml:"period" json:"period,omitempty"`
 }
 
 // Period contains the unmarshalled period XML data.
 type Period struct {
-	XMLName xml.Name      `xml:"period"`
-	Index   string        `xml:"index,attr"`
-	TimeUTC TimeFieldAttr `xml:"time-utc,attr"`
-	Level   Level         `xml:"level"`
+	XMLName   xml.Name      `xml:"period" json:"-"`
+	Index     string        `xml:"index,attr" json:"index,omitempty"`
+	TimeUTC   TimeFieldAttr `xml:"time-utc,attr" json:"time_utc"`
+	TimeLocal TimeFieldAttr `xml:"time-local,attr" json:"time_local"`
+	WindSrc   string        `xml:"wind-src,attr" json:"wind_src,omitempty"`
+	Level     []Level       `xml:"level" json:"level,omitempty"`
 }
 
 // Level contains the unmarshalled level XML data.
 type Level struct {
-	XMLName xml.Name  `xml:"level"`
-	Index   string    `xml:"index,attr"`
-	Type    string    `xml:"type,attr"`
-	Element []Element `xml:"element"`
+	XMLName xml.Name  `xml:"level" json:"-"`
+	Index   string    `xml:"index,attr" json:"index,omitempty"`
+	Type    string    `xml:"type,attr" json:"type,omitempty"`
+	Element []Element `xml:"element" json:"element,omitempty"`
 }
@@ -1,52 +1,151 @@
 package schema
 
 import (
+	"encoding/json"
 	"encoding/xml"
+	"math"
+	"strings"
 	"time"
 )
 
-// Product contains the unmarshalled product XML data.
+// Product contains the unmarshalled product XML data. SchemaVersion holds the
+// schema version detected when parsing, without any leading 'v'.
 type Product struct {
-	XMLName      xml.Name      `xml:"product"`
-	Version      string        `xml:"version"`
-	Amoc         Amoc          `xml:"amoc"`
-	Forecast     *Forecast     `xml:"forecast"`
-	Observations *Observations `xml:"observations"`
+	XMLName        xml.Name      `xml:"product" json:"-"`
+	Version        string        `xml:"version,attr" json:"version,omitempty"`
+	SchemaLocation string        `xml:"noNamespaceSchemaLocation,attr" json:"-"`
+	SchemaVersion  string        `xml:"-" json:"schema_version,omitempty"`
+	Amoc           Amoc          `xml:"amoc" json:"amoc"`
+	Forecast       *Forecast     `xml:"forecast" json:"forecast,omitempty"`
+	Observations   *Observations `xml:"observations" json:"observations,omitempty"`
+	Warning        *Warning      `xml:"warning" json:"warning,omitempty"`
+	Tides          *Tides        `xml:"tides" json:"tides,omitempty"`
 }
 
 // Amoc contains the unmarshalled AMOC XML data.
 type Amoc struct {
-	XMLName                 xml.Name  `xml:"amoc"`
-	Source                  Source    `xml:"source"`
-	Identifier              string    `xml:"identifier"`
-	IssueTimeUTC            TimeField `xml:"issue-time-utc"`
-	NextRoutineIssueTimeUTC TimeField `xml:"next-routine-issue-time-utc"`
-	ProductType             string    `xml:"product-type"`
+	XMLName                   xml.Name       `xml:"amoc" json:"-"`
+	Source                    Source         `xml:"source" json:"source"`
+	Identifier                string         `xml:"identifier" json:"identifier,omitempty"`
+	IssueTimeUTC              TimeField      `xml:"issue-time-utc" json:"issue_time_utc"`
+	IssueTimeLocal            LocalTimeField `xml:"issue-time-local" json:"issue_time_local"`
+	SentTime                  TimeField      `xml:"sent-time" json:"sent_time"`
+	ExpiryTime                TimeField      `xml:"expiry-time" json:"expiry_time"`
+	ValidityBgnTimeLocal      LocalTimeField `xml:"validity-bgn-time-local" json:"validity_bgn_time_local"`
+	ValidityEndTimeLocal      LocalTimeField `xml:"validity-end-time-local" json:"validity_end_time_local"`
+	NextRoutineIssueTimeUTC   TimeField      `xml:"next-routine-issue-time-utc" json:"next_routine_issue_time_utc"`
+	NextRoutineIssueTimeLocal LocalTimeField `xml:"next-routine-issue-time-local" json:"next_routine_issue_time_local"`
+	Status                    string         `xml:"status" json:"status,omitempty"`
+	Service                   string         `xml:"service" json:"service,omitempty"`
+	SubService                string         `xml:"sub-service" json:"sub_service,omitempty"`
+	ProductType               string         `xml:"product-type" json:"product_type,omitempty"`
+	Phase                     string         `xml:"phase" json:"phase,omitempty"`
+	IncidentID                string         `xml:"incident-id" json:"incident_id,omitempty"`
+}
+
+// ValidAt reports whether the product is valid at t, that is within any
+// validity window and before any expiry time given in the AMOC.
+func (a *Amoc) ValidAt(t time.Time) bool {
+	bgn := a.ValidityBgnTimeLocal.Time
+	if !bgn.IsZero() && t.Before(bgn) {
+		return false
+	}
+
+	end := a.ValidityEndTimeLocal.Time
+	if !end.IsZero() && t.After(end) {
+		return false
+	}
+
+	expiry := time.Time(a.ExpiryTime)
+	if !expiry.IsZero() && !t.Before(expiry) {
+		return false
+	}
+
+	return true
 }
 
 // Source contains the unmarshalled source XML data.
 type Source struct {
-	XMLName    xml.Name `xml:"source"`
-	Sender     string   `xml:"sender"`
-	Region     string   `xml:"region"`
-	Office     string   `xml:"office"`
-	Copyright  string   `xml:"copyright"`
-	Disclaimer string   `xml:"disclaimer"`
+	XMLName    xml.Name `xml:"source" json:"-"`
+	Sender     string   `xml:"sender" json:"sender,omitempty"`
+	Region     string   `xml:"region" json:"region,omitempty"`
+	Office     string   `xml:"office" json:"office,omitempty"`
+	Copyright  string   `xml:"copyright" json:"copyright,omitempty"`
+	Disclaimer string   `xml:"disclaimer" json:"disclaimer,omitempty"`
 }
 
 // Element contains an unmarshalled element XML data instance.
+//
+// Accumulations (eg. rainfall) and extremes (eg. maximum_air_temperature)
+// carry the window they cover, extremes additionally carry the time at which
+// they occurred. These are nil when absent.
 type Element struct {
-	XMLName xml.Name `xml:"element"`
-	Type    string   `xml:"type,attr"`
-	Unit    string   `xml:"units,attr"`
-	Value   string   `xml:",chardata"`
+	XMLName        xml.Name       `xml:"element" json:"-"`
+	Type           string         `xml:"type,attr" json:"type,omitempty"`
+	Unit           string         `xml:"units,attr" json:"units,omitempty"`
+	StartTimeLocal *TimeFieldAttr `xml:"start-time-local,attr" json:"start_time_local,omitempty"`
+	EndTimeLocal   *TimeFieldAttr `xml:"end-time-local,attr" json:"end_time_local,omitempty"`
+	StartTimeUTC   *TimeFieldAttr `xml:"start-time-utc,attr" json:"start_time_utc,omitempty"`
+	EndTimeUTC     *TimeFieldAttr `xml:"end-time-utc,attr" json:"end_time_utc,omitempty"`
+	Duration       int            `xml:"duration,attr" json:"duration,omitempty"`
+	Instance       string         `xml:"instance,attr" json:"instance,omitempty"`
+	TimeUTC        *TimeFieldAttr `xml:"time-utc,attr" json:"time_utc,omitempty"`
+	TimeLocal      *TimeFieldAttr `xml:"time-local,attr" json:"time_local,omitempty"`
+	Value          string         `xml:",chardata" json:"value,omitempty"`
+}
+
+// Window returns the period the element covers, using the duration in minutes
+// if given, otherwise the span of the start and end times.
+func (e *Element) Window() time.Duration {
+	if e.Duration > 0 {
+		return time.Duration(e.Duration) * time.Minute
+	}
+
+	if e.StartTimeUTC != nil && e.EndTimeUTC != nil {
+		return time.Time(*e.EndTimeUTC).Sub(time.Time(*e.StartTimeUTC))
+	}
+
+	return 0
+}
+
+// MarshalJSON marshals the element with its value as a number where it is
+// numeric, otherwise as a string. NaN and infinities, which JSON cannot
+// represent, are left as strings.
+func (e Element) MarshalJSON() ([]byte, error) {
+	type element Element
+
+	var value interface{} = e.Value
+	v, err := e.Float()
+	if err == nil && !math.IsNaN(v) && !math.IsInf(v, 0) {
+		value = v
+	}
+
+	return json.Marshal(struct {
+		element
+		Value interface{} `json:"value"`
+	}{element(e), value})
 }
 
-// Text contains an unmarshalled text XML data instance.
+// Text contains an unmarshalled text XML data instance. Some texts, eg.
+// fire_danger, hold a list of paragraphs rather than character data.
 type Text struct {
-	XMLName xml.Name `xml:"text"`
-	Type    string   `xml:"type,attr"`
-	Value   string   `xml:",chardata"`
+	XMLName    xml.Name `xml:"text" json:"-"`
+	Type       string   `xml:"type,attr" json:"type,omitempty"`
+	Value      string   `xml:",chardata" json:"value,omitempty"`
+	Paragraphs []string `xml:"p" json:"p,omitempty"`
+}
+
+// Lines returns the paragraphs of the text, or its trimmed character data if
+// it has none.
+func (t *Text) Lines() []string {
+	if len(t.Paragraphs) > 0 {
+		lines := make([]string, len(t.Paragraphs))
+		for i, p := range t.Paragraphs {
+			lines[i] = strings.TrimSpace(p)
+		}
+		return lines
+	}
+	return []string{strings.TrimSpace(t.Value)}
 }
 
 // TimeField is a wrapper type for time.Time.
@@ -65,6 +164,53 @@ func (t *TimeField) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 	return nil
 }
 
+// MarshalJSON marshals the time as an RFC3339 string, or null if unset.
+func (t TimeField) MarshalJSON() ([]byte, error) {
+	return marshalTime(time.Time(t))
+}
+
+// LocalTimeField is a local time along with the timezone abbreviation given in
+// its 'tz' attribute, eg. CDT.
+type LocalTimeField struct {
+	Time time.Time
+	Zone string
+}
+
+// UnmarshalXML unmarshals an RFC3339 formatted XML string and its 'tz'
+// attribute. The parsed time is placed in a zone named by the abbreviation.
+func (t *LocalTimeField) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var v struct {
+		Zone  string `xml:"tz,attr"`
+		Value string `xml:",chardata"`
+	}
+	d.DecodeElement(&v, &start)
+	tm, err := time.Parse(time.RFC3339, v.Value)
+	if err != nil {
+		return err
+	}
+
+	if v.Zone != "" {
+		_, offset := tm.Zone()
+		tm = tm.In(time.FixedZone(v.Zone, offset))
+	}
+
+	*t = LocalTimeField{Time: tm, Zone: v.Zone}
+	return nil
+}
+
+// MarshalJSON marshals the time as an RFC3339 string with its timezone
+// abbreviation, or null if unset.
+func (t LocalTimeField) MarshalJSON() ([]byte, error) {
+	if t.Time.IsZero() {
+		return []byte("null"), nil
+	}
+
+	return json.Marshal(struct {
+		Time string `json:"time"`
+		Zone string `json:"tz,omitempty"`
+	}{t.Time.Format(time.RFC3339), t.Zone})
+}
+
 // TimeFieldAttr is a wrapper type for time.Time.
 type TimeFieldAttr time.Time
 
@@ -79,7 +225,21 @@ func (t *TimeFieldAttr) UnmarshalXMLAttr(attr xml.Attr) error {
 	return nil
 }
 
-// Parse unmarshals XML data into a top level Product.
+// MarshalJSON marshals the time as an RFC3339 string, or null if unset.
+func (t TimeFieldAttr) MarshalJSON() ([]byte, error) {
+	return marshalTime(time.Time(t))
+}
+
+func marshalTime(t time.Time) ([]byte, error) {
+	if t.IsZero() {
+		return []byte("null"), nil
+	}
+
+	return json.Marshal(t.Format(time.RFC3339))
+}
+
+// Parse unmarshals XML data into a top level Product, accepting any well
+// formed XML.
 func (p *Product) Parse(data []byte) error {
-	return xml.Unmarshal(data, p)
+	return p.ParseMode(data, Lenient)
 }
@@ -1,8 +1,12 @@
 package schema
 
 import (
+	"bytes"
+	"encoding/json"
 	"encoding/xml"
+	"errors"
 	"io/ioutil"
+	"strings"
 	"testing"
 	"time"
 )
@@ -99,6 +103,15 @@ func TestForecastStruct(t *testing.T) {
 
 }
 
+func TestMarineArea(t *testing.T) {
+	for areaType, expected := range map[string]bool{"coast": true, "marine": true, "location": false, "metropolitan": false} {
+		a := Area{Type: areaType}
+		if a.Marine() != expected {
+			t.Errorf("'%s' area reported marine %v, expected %v", areaType, a.Marine(), expected)
+		}
+	}
+}
+
 func TestObservationsStruct(t *testing.T) {
 	inputs := []struct {
 		file     string
@@ -130,8 +143,567 @@ func TestObservationsStruct(t *testing.T) {
 		}
 
 		for _, station := range p.Observations.Station {
-			DumpElements(station.Period.Level.Element)
+			for _, period := range station.Period {
+				for _, level := range period.Level {
+					DumpElements(level.Element)
+				}
+			}
+		}
+	}
+
+}
+
+func TestEncode(t *testing.T) {
+	data, err := ioutil.ReadFile("IDS60920.xml")
+	if err != nil {
+		t.Fatalf("Failed to open 'IDS60920.xml': %s", err)
+	}
+
+	var p Product
+	err = p.Parse(data)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal 'IDS60920.xml': %s", err)
+	}
+
+	var b bytes.Buffer
+	err = p.Encode(&b, FormatJSON)
+	if err != nil {
+		t.Fatalf("Failed to encode JSON: %s", err)
+	}
+
+	var v struct {
+		Amoc struct {
+			IssueTimeUTC            string  `json:"issue_time_utc"`
+			NextRoutineIssueTimeUTC *string `json:"next_routine_issue_time_utc"`
+		} `json:"amoc"`
+		Observations struct {
+			Station []struct {
+				Period []struct {
+					Level []struct {
+						Element []struct {
+							Type  string      `json:"type"`
+							Value interface{} `json:"value"`
+						} `json:"element"`
+					} `json:"level"`
+				} `json:"period"`
+			} `json:"station"`
+		} `json:"observations"`
+	}
+	err = json.Unmarshal(b.Bytes(), &v)
+	if err != nil {
+		t.Fatalf("Failed to decode JSON: %s", err)
+	}
+
+	if v.Amoc.IssueTimeUTC != "2022-05-22T04:41:01Z" {
+		t.Errorf("Unexpected issue time '%s'", v.Amoc.IssueTimeUTC)
+	}
+	if v.Amoc.NextRoutineIssueTimeUTC != nil {
+		t.Errorf("Expected unset time to be null, got '%s'", *v.Amoc.NextRoutineIssueTimeUTC)
+	}
+
+	for _, e := range v.Observations.Station[0].Period[0].Level[0].Element {
+		switch e.Type {
+		case "air_temperature":
+			if e.Value != 20.9 {
+				t.Errorf("Expected numeric air_temperature, got %#v", e.Value)
+			}
+		case "cloud":
+			if e.Value != "Cloudy" {
+				t.Errorf("Expected string cloud, got %#v", e.Value)
+			}
+		}
+	}
+
+	var y1, y2 bytes.Buffer
+	p.Encode(&y1, FormatYAML)
+	p.Encode(&y2, FormatYAML)
+	if !bytes.Equal(y1.Bytes(), y2.Bytes()) {
+		t.Errorf("YAML output is not stable")
+	}
+	if !strings.Contains(y1.String(), "identifier: IDS60920") {
+		t.Errorf("Unexpected YAML output:\n%.500s", y1.String())
+	}
+
+	if p.Encode(&b, Format("xml")) == nil {
+		t.Errorf("Expected error for unknown format")
+	}
+
+	for _, value := range []string{"NaN", "Inf", "-Inf"} {
+		data, err := json.Marshal(Element{Type: "air_temperature", Value: value})
+		if err != nil {
+			t.Errorf("Failed to encode '%s': %s", value, err)
+		}
+		if !strings.Contains(string(data), `"value":"`+value+`"`) {
+			t.Errorf("Expected string value '%s', got %s", value, data)
+		}
+	}
+}
+
+func TestLocalTimeField(t *testing.T) {
+	v := []struct {
+		input    []byte
+		expected time.Time
+		zone     string
+	}{
+		{[]byte(`<test><time tz="CDT">2022-03-29T16:32:13+10:30</time></test>`), time.Date(2022, time.March, 29, 6, 2, 13, 0, time.UTC), "CDT"},
+		{[]byte(`<test><time tz="CST">2022-04-05T23:59:59+09:30</time></test>`), time.Date(2022, time.April, 5, 14, 29, 59, 0, time.UTC), "CST"},
+		{[]byte(`<test><time>2022-06-04T16:11:02+10:00</time></test>`), time.Date(2022, time.June, 4, 6, 11, 2, 0, time.UTC), ""},
+	}
+
+	for _, k := range v {
+		tt := struct {
+			TTime LocalTimeField `xml:"time"`
+		}{}
+		err := xml.Unmarshal(k.input, &tt)
+		if err != nil {
+			t.Errorf("Failed to unmarshal '%s': %s", k.input, err)
 		}
+		if !tt.TTime.Time.Equal(k.expected) {
+			t.Errorf("Parse mismatch; %+v != %+v", tt.TTime.Time, k.expected)
+		}
+		if tt.TTime.Zone != k.zone {
+			t.Errorf("Zone mismatch; %s != %s", tt.TTime.Zone, k.zone)
+		}
+		if k.zone != "" && tt.TTime.Time.Format("MST") != k.zone {
+			t.Errorf("Time zone not kept; %s != %s", tt.TTime.Time.Format("MST"), k.zone)
+		}
+	}
+}
+
+func TestAmoc(t *testing.T) {
+	data, err := ioutil.ReadFile("IDS10034.xml")
+	if err != nil {
+		t.Fatalf("Failed to open 'IDS10034.xml': %s", err)
+	}
+
+	var p Product
+	err = p.Parse(data)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal 'IDS10034.xml': %s", err)
+	}
+
+	a := p.Amoc
+	if a.IssueTimeLocal.Zone != "CDT" || a.ValidityEndTimeLocal.Zone != "CST" || a.NextRoutineIssueTimeLocal.Zone != "CDT" {
+		t.Errorf("Unexpected zones %s, %s, %s", a.IssueTimeLocal.Zone, a.ValidityEndTimeLocal.Zone, a.NextRoutineIssueTimeLocal.Zone)
+	}
+	if !time.Time(a.SentTime).Equal(time.Date(2022, time.March, 29, 6, 2, 13, 0, time.UTC)) {
+		t.Errorf("Unexpected sent time %v", time.Time(a.SentTime))
 	}
+	if a.Status != "O" || a.Service != "WSP" || a.SubService != "FCT" || a.Phase != "UPD" {
+		t.Errorf("Unexpected status/service/sub-service/phase %s/%s/%s/%s", a.Status, a.Service, a.SubService, a.Phase)
+	}
+
+	v := []struct {
+		at    time.Time
+		valid bool
+	}{
+		{time.Date(2022, time.March, 29, 6, 0, 0, 0, time.UTC), false},
+		{time.Date(2022, time.March, 29, 12, 0, 0, 0, time.UTC), true},
+		{time.Date(2022, time.March, 29, 21, 2, 13, 0, time.UTC), false},
+	}
+	for _, k := range v {
+		if a.ValidAt(k.at) != k.valid {
+			t.Errorf("ValidAt(%v) != %v", k.at, k.valid)
+		}
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	inputs := []struct {
+		file    string
+		version string
+	}{
+		{"IDS10034.xml", "1.7"},
+		{"IDS10044.xml", "1.7"},
+		{"IDS60920.xml", "1.7.1"},
+		{"IDT60920.xml", "1.7.1"},
+	}
+
+	for _, x := range inputs {
+		data, err := ioutil.ReadFile(x.file)
+		if err != nil {
+			t.Fatalf("Failed to open '%s': %s", x.file, err)
+		}
+
+		var p Product
+		err = p.ParseMode(data, Strict)
+		if err != nil {
+			t.Errorf("Strict parse of '%s' failed: %s", x.file, err)
+		}
+		if p.SchemaVersion != x.version {
+			t.Errorf("Detected version '%s' for '%s', expected '%s'", p.SchemaVersion, x.file, x.version)
+		}
+	}
+
+	data, err := ioutil.ReadFile("../connection/test.xml")
+	if err != nil {
+		t.Fatalf("Failed to open 'test.xml': %s", err)
+	}
+
+	var p Product
+	err = p.Parse(data)
+	if err != nil {
+		t.Errorf("Lenient parse of 'test.xml' failed: %s", err)
+	}
+
+	err = p.ParseMode(data, Strict)
+	var ve *ValidationError
+	if !errors.As(err, &ve) {
+		t.Fatalf("Expected ValidationError, got %v", err)
+	}
+
+	fields := map[string]bool{}
+	for _, problem := range ve.Problems {
+		fields[problem.Field] = true
+	}
+	for _, f := range []string{"version", "amoc.identifier", "amoc.issue-time-utc", "forecast"} {
+		if !fields[f] {
+			t.Errorf("Expected problem with '%s', got %+v", f, ve.Problems)
+		}
+	}
+	if fields["amoc.phase"] {
+		t.Errorf("Unexpected problem with 'amoc.phase'")
+	}
+}
+
+func TestDetectVersion(t *testing.T) {
+	v := []struct {
+		input    string
+		expected string
+	}{
+		{`<product version="1.7"/>`, "1.7"},
+		{`<product version="v1.7.1"/>`, "1.7.1"},
+		{`<product xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:noNamespaceSchemaLocation="http://www.bom.gov.au/schema/v1.8/product.xsd"/>`, "1.8"},
+		{`<product/>`, ""},
+	}
+
+	for _, k := range v {
+		var p Product
+		err := p.Parse([]byte(k.input))
+		if err != nil {
+			t.Errorf("Failed to parse '%s': %s", k.input, err)
+		}
+		if p.SchemaVersion != k.expected {
+			t.Errorf("Detected '%s' from '%s', expected '%s'", p.SchemaVersion, k.input, k.expected)
+		}
+	}
+}
+
+// collectHandler keeps everything passed to it by Decode.
+type collectHandler struct {
+	header   *Product
+	areas    []Area
+	stations []Station
+	err      error
+}
+
+func (h *collectHandler) Header(p *Product) error {
+	h.header = p
+	return nil
+}
+
+func (h *collectHandler) Area(a *Area) error {
+	h.areas = append(h.areas, *a)
+	return h.err
+}
+
+func (h *collectHandler) Station(s *Station) error {
+	h.stations = append(h.stations, *s)
+	return h.err
+}
+
+func TestDecode(t *testing.T) {
+	for _, file := range []string{"IDS10034.xml", "IDS10044.xml", "IDS60920.xml", "IDT60920.xml"} {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatalf("Failed to open '%s': %s", file, err)
+		}
 
+		var p Product
+		err = p.Parse(data)
+		if err != nil {
+			t.Fatalf("Failed to unmarshal '%s': %s", file, err)
+		}
+
+		var h collectHandler
+		err = Decode(bytes.NewReader(data), &h)
+		if err != nil {
+			t.Fatalf("Failed to decode '%s': %s", file, err)
+		}
+
+		if h.header == nil {
+			t.Fatalf("%s: header not decoded", file)
+		}
+		if h.header.Version != p.Version || h.header.SchemaVersion != p.SchemaVersion {
+			t.Errorf("%s: got version %s (%s), expected %s (%s)", file, h.header.Version, h.header.SchemaVersion, p.Version, p.SchemaVersion)
+		}
+		if h.header.Amoc.Identifier != p.Amoc.Identifier || h.header.Amoc.IssueTimeUTC != p.Amoc.IssueTimeUTC {
+			t.Errorf("%s: got amoc %+v, expected %+v", file, h.header.Amoc, p.Amoc)
+		}
+
+		if p.Forecast != nil {
+			if h.header.Forecast == nil {
+				t.Errorf("%s: expected forecast header", file)
+			}
+			if len(h.areas) != len(p.Forecast.Area) {
+				t.Errorf("%s: got %d areas, expected %d", file, len(h.areas), len(p.Forecast.Area))
+			}
+		}
+
+		if p.Observations != nil {
+			if h.header.Observations == nil {
+				t.Errorf("%s: expected observations header", file)
+			}
+			if len(h.stations) != len(p.Observations.Station) {
+				t.Errorf("%s: got %d stations, expected %d", file, len(h.stations), len(p.Observations.Station))
+			}
+			if len(h.stations) > 0 && h.stations[0].Name != p.Observations.Station[0].Name {
+				t.Errorf("%s: got station %s, expected %s", file, h.stations[0].Name, p.Observations.Station[0].Name)
+			}
+		}
+	}
+
+	// Errors from the handler stop decoding.
+	data, _ := ioutil.ReadFile("IDS60920.xml")
+	h := collectHandler{err: errors.New("stop")}
+	err := Decode(bytes.NewReader(data), &h)
+	if err == nil || len(h.stations) != 1 {
+		t.Errorf("Got %v after %d stations, expected stop after 1", err, len(h.stations))
+	}
+
+	err = Decode(strings.NewReader("<product><amoc>"), &collectHandler{})
+	if err == nil {
+		t.Errorf("Expected error decoding truncated product")
+	}
+}
+
+func TestDecoder(t *testing.T) {
+	for _, file := range []string{"IDS10034.xml", "IDS10044.xml", "IDS60920.xml", "IDT60920.xml"} {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatalf("Failed to open '%s': %s", file, err)
+		}
+
+		err = NewDecoder(bytes.NewReader(data), Strict).Decode(&collectHandler{})
+		if err != nil {
+			t.Errorf("Strict decode of '%s' failed: %s", file, err)
+		}
+	}
+
+	// Header problems are found before any area is decoded.
+	data, err := ioutil.ReadFile("../connection/test.xml")
+	if err != nil {
+		t.Fatalf("Failed to open 'test.xml': %s", err)
+	}
+
+	_, err = NewDecoder(bytes.NewReader(data), Strict).Header()
+	var ve *ValidationError
+	if !errors.As(err, &ve) {
+		t.Fatalf("Expected ValidationError, got %v", err)
+	}
+	for _, problem := range ve.Problems {
+		if problem.Field == "forecast" {
+			t.Errorf("Unexpected content problem before decoding areas: %+v", problem)
+		}
+	}
+
+	// Content problems are found once the product has been read.
+	var p Product
+	err = p.Parse(data)
+	if err != nil {
+		t.Fatalf("Failed to parse 'test.xml': %s", err)
+	}
+	problems := p.contentProblems(0)
+	if len(problems) != 1 || problems[0].Field != "forecast" {
+		t.Errorf("Got %+v, expected a forecast problem", problems)
+	}
+
+	// CAP alerts are read in full by Header.
+	data, err = ioutil.ReadFile("cap.xml")
+	if err != nil {
+		t.Fatalf("Failed to open 'cap.xml': %s", err)
+	}
+
+	d := NewDecoder(bytes.NewReader(data), Strict)
+	h, err := d.Header()
+	if err != nil || h != nil || d.Alert() == nil {
+		t.Errorf("Got product %v, alert %v, error %v, expected an alert", h, d.Alert(), err)
+	}
+	if d.Decode(&collectHandler{}) == nil {
+		t.Errorf("Expected error decoding an alert as a product")
+	}
+}
+
+// discardHandler ignores everything passed to it by Decode.
+type discardHandler struct{}
+
+func (discardHandler) Header(p *Product) error  { return nil }
+func (discardHandler) Area(a *Area) error       { return nil }
+func (discardHandler) Station(s *Station) error { return nil }
+
+func benchmarkParse(b *testing.B, file string) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		b.Fatalf("Failed to open '%s': %s", file, err)
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var p Product
+		err := p.Parse(data)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchmarkDecode(b *testing.B, file string) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		b.Fatalf("Failed to open '%s': %s", file, err)
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		err := Decode(bytes.NewReader(data), discardHandler{})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseIDS10044(b *testing.B)  { benchmarkParse(b, "IDS10044.xml") }
+func BenchmarkDecodeIDS10044(b *testing.B) { benchmarkDecode(b, "IDS10044.xml") }
+func BenchmarkParseIDS60920(b *testing.B)  { benchmarkParse(b, "IDS60920.xml") }
+func BenchmarkDecodeIDS60920(b *testing.B) { benchmarkDecode(b, "IDS60920.xml") }
+
+func TestQuantity(t *testing.T) {
+	inputs := []struct {
+		element  Element
+		to       Unit
+		expected float64
+	}{
+		{Element{Value: "10", Unit: "knots"}, UnitMetersPerSecond, 5.144444},
+		{Element{Value: "36", Unit: "km/h"}, UnitMetersPerSecond, 10},
+		{Element{Value: "10", Unit: "knots"}, UnitKilometersPerHour, 18.52},
+		{Element{Value: "1013.2", Unit: "hPa"}, UnitPascals, 101320},
+		{Element{Value: "21.5", Unit: "Celsius"}, UnitKelvin, 294.65},
+		{Element{Value: "-5", Unit: "Celsius"}, UnitCelsius, -5},
+		{Element{Value: "85", Unit: "%"}, UnitRatio, 0.85},
+		{Element{Value: "12.4", Unit: "mm"}, UnitMeters, 0.0124},
+		{Element{Value: "10", Unit: "km"}, UnitMeters, 10000},
+	}
+
+	for _, x := range inputs {
+		q, err := x.element.Quantity()
+		if err != nil {
+			t.Fatalf("Failed to get quantity of %+v: %s", x.element, err)
+		}
+		if q.Unit.String() != x.element.Unit {
+			t.Errorf("Got unit '%s', expected '%s'", q.Unit, x.element.Unit)
+		}
+
+		c, err := q.Convert(x.to)
+		if err != nil {
+			t.Fatalf("Failed to convert %+v to %s: %s", q, x.to, err)
+		}
+		if c.Unit != x.to || c.Value < x.expected-1e-6 || c.Value > x.expected+1e-6 {
+			t.Errorf("Converted %+v to %+v, expected %f %s", q, c, x.expected, x.to)
+		}
+	}
+
+	if UnitKnots.Base() != UnitMetersPerSecond || UnitDegrees.Base() != UnitDegrees {
+		t.Errorf("Unexpected base units")
+	}
+
+	q := Quantity{Value: 1, Unit: UnitCelsius}
+	if _, err := q.Convert(UnitPascals); err == nil {
+		t.Errorf("Expected error converting Celsius to Pa")
+	}
+
+	e := Element{Value: "ENE", Unit: "deg"}
+	if _, err := e.Quantity(); err == nil {
+		t.Errorf("Expected error for non-numeric value")
+	}
+
+	if ParseUnit("furlongs") != UnitUnknown {
+		t.Errorf("Expected unknown unit")
+	}
+}
+
+func TestWarningStruct(t *testing.T) {
+	data, err := ioutil.ReadFile("IDS21037.xml")
+	if err != nil {
+		t.Fatalf("Failed to open: %s", err)
+	}
+
+	var p Product
+	err = p.ParseMode(data, Strict)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal: %s", err)
+	}
+
+	if p.Warning == nil || p.Warning.Info.Type != "severe_weather" {
+		t.Fatalf("Failed to unmarshal warning: %+v", p.Warning)
+	}
+	if p.Amoc.IncidentID != "IDS21037-20220604-0002" {
+		t.Errorf("Got incident id '%s'", p.Amoc.IncidentID)
+	}
+	if len(p.Warning.Area) != 3 || p.Warning.Area[2].Phase != "CAN" {
+		t.Errorf("Failed to unmarshal warning areas: %+v", p.Warning.Area)
+	}
+	if len(p.Warning.Area[1].Phenomena) != 2 || len(p.Warning.Info.Phenomena) != 1 {
+		t.Errorf("Failed to unmarshal phenomena")
+	}
+
+	p.Warning.Area = nil
+	if p.Validate() == nil {
+		t.Errorf("Expected strict problem for warning without areas")
+	}
+}
+
+func TestAlert(t *testing.T) {
+	data, err := ioutil.ReadFile("cap.xml")
+	if err != nil {
+		t.Fatalf("Failed to open: %s", err)
+	}
+
+	root, err := RootElement(data)
+	if err != nil || root != "alert" {
+		t.Errorf("Got root '%s', %v, expected alert", root, err)
+	}
+
+	var a Alert
+	err = a.Parse(data)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal: %s", err)
+	}
+
+	if a.MsgType != "Update" || len(a.Info) != 2 || len(a.Info[0].Area) != 2 {
+		t.Fatalf("Failed to unmarshal alert: %+v", a)
+	}
+
+	area := a.Info[0].Area[0]
+	if area.Geocode("AMOC-AreaCode") != "SA_PW001" || len(area.Polygons) != 1 || len(area.Polygons[0]) != 5 {
+		t.Errorf("Failed to unmarshal area: %+v", area)
+	}
+	if !area.Contains(-34.93, 138.6) || area.Contains(-33.0, 138.6) {
+		t.Errorf("Unexpected polygon containment")
+	}
+
+	sent := time.Date(2022, time.June, 4, 4, 40, 0, 0, time.UTC)
+	if !a.Info[0].ActiveAt(sent) || a.Info[0].ActiveAt(sent.Add(6*time.Hour)) || a.Info[1].ActiveAt(sent) {
+		t.Errorf("Unexpected active times")
+	}
+
+	err = Decode(bytes.NewReader(data), &collectHandler{})
+	if err == nil {
+		t.Errorf("Expected error decoding an alert as a product")
+	}
+
+	var p Polygon
+	err = xml.Unmarshal([]byte("<polygon>-34.6,138.4 -34.6</polygon>"), &p)
+	if err == nil {
+		t.Errorf("Expected error for malformed polygon")
+	}
 }
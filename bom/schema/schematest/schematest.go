@@ -0,0 +1,25 @@
+package schematest
+
+import (
+	"github.com/gkoh/bom_exporter/bom/schema"
+	"os"
+	"testing"
+)
+
+// ParseFile reads and parses the product XML file at path, accepting any well
+// formed XML, failing the test if it cannot.
+func ParseFile(t testing.TB, path string) *schema.Product {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to open '%s': %s", path, err)
+	}
+
+	var p schema.Product
+	err = p.Parse(data)
+	if err != nil {
+		t.Fatalf("Failed to parse '%s': %s", path, err)
+	}
+	return &p
+}
@@ -0,0 +1,101 @@
+package schema
+
+import (
+	"encoding/xml"
+	"math"
+	"strconv"
+	"time"
+)
+
+// Tides contains the unmarshalled tide prediction XML data. The layout is
+// assumed, it has not yet been checked against a real tide product, see
+// tides.xml.
+type Tides struct {
+	XMLName xml.Name `xml:"tides" json:"-"`
+	Port    []Port   `xml:"port" json:"port,omitempty"`
+}
+
+// Port contains the unmarshalled tide predictions for a single port.
+type Port struct {
+	XMLName     xml.Name `xml:"port" json:"-"`
+	Aac         string   `xml:"aac,attr" json:"aac,omitempty"`
+	Description string   `xml:"description,attr" json:"description,omitempty"`
+	Latitude    float32  `xml:"lat,attr" json:"lat"`
+	Longitude   float32  `xml:"lon,attr" json:"lon"`
+	Timezone    string   `xml:"tz,attr" json:"tz,omitempty"`
+	Tide        []Tide   `xml:"tide" json:"tide,omitempty"`
+}
+
+// Tide contains a single predicted high or low tide.
+type Tide struct {
+	Type      string        `xml:"type,attr" json:"type,omitempty"`
+	TimeLocal TimeFieldAttr `xml:"time-local,attr" json:"time_local"`
+	TimeUTC   TimeFieldAttr `xml:"time-utc,attr" json:"time_utc"`
+	Unit      string        `xml:"units,attr" json:"units,omitempty"`
+	Value     string        `xml:",chardata" json:"value"`
+}
+
+// Quantity returns the predicted height of the tide with its unit.
+func (t *Tide) Quantity() (Quantity, error) {
+	v, err := strconv.ParseFloat(t.Value, 64)
+	if err != nil {
+		return Quantity{}, err
+	}
+	return Quantity{Value: v, Unit: ParseUnit(t.Unit)}, nil
+}
+
+// Next returns the first tide of the given type ("high" or "low") predicted
+// after t, or nil if there is none.
+func (p *Port) Next(tideType string, t time.Time) *Tide {
+	var next *Tide
+	for i := range p.Tide {
+		tide := &p.Tide[i]
+		at := time.Time(tide.TimeUTC)
+		if tide.Type != tideType || !at.After(t) {
+			continue
+		}
+		if next == nil || at.Before(time.Time(next.TimeUTC)) {
+			next = tide
+		}
+	}
+	return next
+}
+
+// HeightAt returns the tide height at t, interpolated between the surrounding
+// predictions with the cosine (rule of twelfths) approximation. It reports
+// false if t is not between two predictions.
+func (p *Port) HeightAt(t time.Time) (Quantity, bool) {
+	var before, after *Tide
+	for i := range p.Tide {
+		tide := &p.Tide[i]
+		at := time.Time(tide.TimeUTC)
+		if !at.After(t) && (before == nil || at.After(time.Time(before.TimeUTC))) {
+			before = tide
+		}
+		if at.After(t) && (after == nil || at.Before(time.Time(after.TimeUTC))) {
+			after = tide
+		}
+	}
+	if before == nil || after == nil {
+		return Quantity{}, false
+	}
+
+	h1, err := before.Quantity()
+	if err != nil {
+		return Quantity{}, false
+	}
+	h2, err := after.Quantity()
+	if err != nil {
+		return Quantity{}, false
+	}
+	h2, err = h2.Convert(h1.Unit)
+	if err != nil {
+		return Quantity{}, false
+	}
+
+	t1 := time.Time(before.TimeUTC)
+	t2 := time.Time(after.TimeUTC)
+	phase := math.Pi * float64(t.Sub(t1)) / float64(t2.Sub(t1))
+
+	return Quantity{Value: h1.Value + (h2.Value-h1.Value)*(1-math.Cos(phase))/2, Unit: h1.Unit}, true
+}
@@ -0,0 +1,137 @@
+package schema
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Unit is the canonical form of the units attribute of an element.
+type Unit int
+
+// Units used by BoM products, plus the SI units they convert to.
+const (
+	UnitUnknown Unit = iota
+	UnitCelsius
+	UnitKelvin
+	UnitKnots
+	UnitKilometersPerHour
+	UnitMetersPerSecond
+	UnitHectopascals
+	UnitPascals
+	UnitPercent
+	UnitRatio
+	UnitDegrees
+	UnitMillimeters
+	UnitMeters
+	UnitKilometers
+	UnitSeconds
+	UnitOktas
+)
+
+// unitNames holds the spelling of each unit used in BoM products.
+var unitNames = map[Unit]string{
+	UnitCelsius:           "Celsius",
+	UnitKelvin:            "Kelvin",
+	UnitKnots:             "knots",
+	UnitKilometersPerHour: "km/h",
+	UnitMetersPerSecond:   "m/s",
+	UnitHectopascals:      "hPa",
+	UnitPascals:           "Pa",
+	UnitPercent:           "%",
+	UnitRatio:             "ratio",
+	UnitDegrees:           "deg",
+	UnitMillimeters:       "mm",
+	UnitMeters:            "m",
+	UnitKilometers:        "km",
+	UnitSeconds:           "s",
+	UnitOktas:             "oktas",
+}
+
+// unitsByName is the reverse of unitNames.
+var unitsByName = func() map[string]Unit {
+	m := make(map[string]Unit, len(unitNames))
+	for u, name := range unitNames {
+		m[name] = u
+	}
+	return m
+}()
+
+// ParseUnit returns the Unit for a units attribute, or UnitUnknown.
+func ParseUnit(s string) Unit {
+	return unitsByName[strings.TrimSpace(s)]
+}
+
+// String returns the unit as spelt in BoM products.
+func (u Unit) String() string {
+	return unitNames[u]
+}
+
+// scale relates a unit to the base unit of its quantity, such that
+// base = value * factor + offset.
+type scale struct {
+	base   Unit
+	factor float64
+	offset float64
+}
+
+var scales = map[Unit]scale{
+	UnitCelsius:           {UnitKelvin, 1, 273.15},
+	UnitKelvin:            {UnitKelvin, 1, 0},
+	UnitKnots:             {UnitMetersPerSecond, 1852.0 / 3600.0, 0},
+	UnitKilometersPerHour: {UnitMetersPerSecond, 1000.0 / 3600.0, 0},
+	UnitMetersPerSecond:   {UnitMetersPerSecond, 1, 0},
+	UnitHectopascals:      {UnitPascals, 100, 0},
+	UnitPascals:           {UnitPascals, 1, 0},
+	UnitPercent:           {UnitRatio, 0.01, 0},
+	UnitRatio:             {UnitRatio, 1, 0},
+	UnitMillimeters:       {UnitMeters, 0.001, 0},
+	UnitMeters:            {UnitMeters, 1, 0},
+	UnitKilometers:        {UnitMeters, 1000, 0},
+}
+
+// Base returns the SI unit u converts to, or u itself if it has none.
+func (u Unit) Base() Unit {
+	s, ok := scales[u]
+	if !ok {
+		return u
+	}
+	return s.base
+}
+
+// Quantity is a numeric element value with its unit.
+type Quantity struct {
+	Value float64
+	Unit  Unit
+}
+
+// Convert returns q expressed in the given unit. Only units of the same
+// quantity (eg. knots and km/h) can be converted.
+func (q Quantity) Convert(to Unit) (Quantity, error) {
+	if q.Unit == to {
+		return q, nil
+	}
+
+	from, ok := scales[q.Unit]
+	target, ok2 := scales[to]
+	if !ok || !ok2 || from.base != target.base {
+		return Quantity{}, fmt.Errorf("Cannot convert '%s' to '%s'", q.Unit, to)
+	}
+
+	base := q.Value*from.factor + from.offset
+	return Quantity{Value: (base - target.offset) / target.factor, Unit: to}, nil
+}
+
+// Float returns the numeric value of the element.
+func (e *Element) Float() (float64, error) {
+	return strconv.ParseFloat(e.Value, 64)
+}
+
+// Quantity returns the numeric value of the element with its unit.
+func (e *Element) Quantity() (Quantity, error) {
+	v, err := e.Float()
+	if err != nil {
+		return Quantity{}, err
+	}
+	return Quantity{Value: v, Unit: ParseUnit(e.Unit)}, nil
+}
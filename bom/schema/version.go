@@ -0,0 +1,197 @@
+package schema
+
+import (
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// KnownVersions lists the product schema versions accepted in Strict mode,
+// normalised to major.minor. No differences between versions are known, so
+// the version does not change how a product is decoded.
+var KnownVersions = []string{"1.7"}
+
+// Mode controls how strictly a product is validated when parsed.
+type Mode int
+
+const (
+	// Lenient accepts any well formed XML.
+	Lenient Mode = iota
+	// Strict rejects unknown schema versions, missing mandatory AMOC fields
+	// and products without content.
+	Strict
+)
+
+// Problem describes a single strict mode validation failure.
+type Problem struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError is returned when a product fails strict mode validation.
+type ValidationError struct {
+	Identifier string    `json:"identifier,omitempty"`
+	Version    string    `json:"version,omitempty"`
+	Problems   []Problem `json:"problems"`
+}
+
+// Error implements the error interface.
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Problems))
+	for _, p := range e.Problems {
+		msgs = append(msgs, p.Field+": "+p.Message)
+	}
+
+	return fmt.Sprintf("Product '%s' (version '%s') failed validation: %s", e.Identifier, e.Version, strings.Join(msgs, "; "))
+}
+
+// schemaLocation extracts the version from a schema location such as
+// http://www.bom.gov.au/schema/v1.7/product.xsd.
+var schemaLocation = regexp.MustCompile(`/schema/v?([0-9]+(?:\.[0-9]+)*)/`)
+
+// normaliseVersion strips any leading 'v', eg. "v1.7.1" becomes "1.7.1".
+func normaliseVersion(v string) string {
+	return strings.TrimPrefix(strings.TrimSpace(v), "v")
+}
+
+// majorMinor truncates a normalised version to major.minor.
+func majorMinor(v string) string {
+	parts := strings.SplitN(v, ".", 3)
+	if len(parts) > 2 {
+		parts = parts[:2]
+	}
+
+	return strings.Join(parts, ".")
+}
+
+// detectVersion determines the schema version from the version attribute,
+// falling back to the schema location, which some products declare alone.
+func (p *Product) detectVersion() {
+	p.SchemaVersion = normaliseVersion(p.Version)
+	if p.SchemaVersion != "" {
+		return
+	}
+
+	m := schemaLocation.FindStringSubmatch(p.SchemaLocation)
+	if m != nil {
+		p.SchemaVersion = m[1]
+	}
+}
+
+// ParseMode unmarshals XML data into a top level Product, validating it
+// according to mode. In Strict mode a *ValidationError is returned for a well
+// formed product that fails validation.
+func (p *Product) ParseMode(data []byte, mode Mode) error {
+	err := xml.Unmarshal(data, p)
+	if err != nil {
+		return err
+	}
+
+	p.detectVersion()
+
+	if mode == Strict {
+		return p.Validate()
+	}
+
+	return nil
+}
+
+// Validate checks the product against the strict mode rules, returning a
+// *ValidationError listing every problem found.
+func (p *Product) Validate() error {
+	return p.validationError(append(p.headerProblems(), p.contentProblems(p.contentCount())...))
+}
+
+// validationError returns a *ValidationError for problems, or nil if there
+// are none.
+func (p *Product) validationError(problems []Problem) error {
+	if len(problems) == 0 {
+		return nil
+	}
+
+	return &ValidationError{Identifier: p.Amoc.Identifier, Version: p.SchemaVersion, Problems: problems}
+}
+
+// headerProblems checks the schema version and the mandatory AMOC fields.
+func (p *Product) headerProblems() []Problem {
+	var problems []Problem
+
+	problem := func(field string, format string, args ...interface{}) {
+		problems = append(problems, Problem{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if p.SchemaVersion == "" {
+		problem("version", "missing")
+	} else if !slices.Contains(KnownVersions, majorMinor(p.SchemaVersion)) {
+		problem("version", "unknown version '%s'", p.SchemaVersion)
+	}
+
+	required := []struct {
+		field string
+		value string
+	}{
+		{"amoc.source.sender", p.Amoc.Source.Sender},
+		{"amoc.source.region", p.Amoc.Source.Region},
+		{"amoc.identifier", p.Amoc.Identifier},
+		{"amoc.status", p.Amoc.Status},
+		{"amoc.service", p.Amoc.Service},
+		{"amoc.product-type", p.Amoc.ProductType},
+		{"amoc.phase", p.Amoc.Phase},
+	}
+	for _, r := range required {
+		if r.value == "" {
+			problem(r.field, "missing")
+		}
+	}
+
+	if time.Time(p.Amoc.IssueTimeUTC).IsZero() {
+		problem("amoc.issue-time-utc", "missing")
+	}
+	if time.Time(p.Amoc.SentTime).IsZero() {
+		problem("amoc.sent-time", "missing")
+	}
+
+	return problems
+}
+
+// contentCount returns the number of areas, stations or ports of the product.
+func (p *Product) contentCount() int {
+	switch {
+	case p.Forecast != nil:
+		return len(p.Forecast.Area)
+	case p.Observations != nil:
+		return len(p.Observations.Station)
+	case p.Warning != nil:
+		return len(p.Warning.Area)
+	case p.Tides != nil:
+		return len(p.Tides.Port)
+	}
+	return 0
+}
+
+// contentProblems checks that the product has content, given the number of
+// its areas, stations or ports, which a streamed product counts as it is
+// decoded.
+func (p *Product) contentProblems(count int) []Problem {
+	field, message := "product", "no forecast, observations, warning or tides"
+	switch {
+	case p.Forecast != nil:
+		field, message = "forecast", "no areas"
+	case p.Observations != nil:
+		field, message = "observations", "no stations"
+	case p.Warning != nil:
+		field, message = "warning", "no areas"
+	case p.Tides != nil:
+		field, message = "tides", "no ports"
+	default:
+		count = 0
+	}
+
+	if count > 0 {
+		return nil
+	}
+	return []Problem{{Field: field, Message: message}}
+}
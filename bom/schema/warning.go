@@ -0,0 +1,40 @@
+package schema
+
+import (
+	"encoding/xml"
+)
+
+// Warning contains the unmarshalled warning XML data.
+type Warning struct {
+	XMLName xml.Name      `xml:"warning" json:"-"`
+	Info    WarningInfo   `xml:"warning-info" json:"warning_info"`
+	Area    []WarningArea `xml:"area" json:"area,omitempty"`
+	Texts   []Text        `xml:"text" json:"text,omitempty"`
+}
+
+// WarningInfo contains the unmarshalled warning-info XML data.
+type WarningInfo struct {
+	Type      string       `xml:"warning-type" json:"warning_type,omitempty"`
+	Headline  string       `xml:"headline" json:"headline,omitempty"`
+	Phenomena []Phenomenon `xml:"phenomena>phenomenon" json:"phenomena,omitempty"`
+}
+
+// Phenomenon contains the unmarshalled phenomenon XML data, the hazard being
+// warned for and its severity.
+type Phenomenon struct {
+	Type     string `xml:"type,attr" json:"type,omitempty"`
+	Severity string `xml:"severity,attr" json:"severity,omitempty"`
+}
+
+// WarningArea contains the unmarshalled area XML data of a warning. Phase, if
+// given, overrides the AMOC phase for the area, eg. CAN for an area removed
+// from an updated warning. Phenomena, if given, override those of the warning.
+type WarningArea struct {
+	XMLName     xml.Name     `xml:"area" json:"-"`
+	Aac         string       `xml:"aac,attr" json:"aac,omitempty"`
+	Description string       `xml:"description,attr" json:"description,omitempty"`
+	Type        string       `xml:"type,attr" json:"type,omitempty"`
+	ParentAac   string       `xml:"parent-aac,attr" json:"parent_aac,omitempty"`
+	Phase       string       `xml:"phase,attr" json:"phase,omitempty"`
+	Phenomena   []Phenomenon `xml:"phenomenon" json:"phenomena,omitempty"`
+}
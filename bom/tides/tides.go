@@ -0,0 +1,133 @@
+package tides
+
+import (
+	"fmt"
+	"github.com/gkoh/bom_exporter/bom/schema"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+// MetricNames is the list of metrics exported by the tides collector.
+var MetricNames = []string{
+	"bom_tides_next_height_meters",
+	"bom_tides_next_time_seconds",
+	"bom_tides_height_meters",
+}
+
+// tideTypes are the predicted tide types, as found in the product.
+var tideTypes = []string{"high", "low"}
+
+// Tides combines the unmarshalled tide predictions and the corresponding
+// Prometheus output metrics.
+type Tides struct {
+	product     *schema.Product
+	interpolate bool
+	now         func() time.Time
+	nextDesc    *prometheus.Desc
+	timeDesc    *prometheus.Desc
+	heightDesc  *prometheus.Desc
+}
+
+// New creates a tides collector based on an unmarshalled Product.
+func New(product *schema.Product) *Tides {
+	return newTides(product, false)
+}
+
+// NewWithInterpolation creates a tides collector which also exports the tide
+// height interpolated at scrape time.
+func NewWithInterpolation(product *schema.Product) *Tides {
+	return newTides(product, true)
+}
+
+func newTides(product *schema.Product, interpolate bool) *Tides {
+	var t Tides
+
+	t.product = product
+	t.interpolate = interpolate
+	t.now = time.Now
+
+	labels := prometheus.Labels{"identifier": product.Amoc.Identifier}
+	portLabels := []string{"aac", "description", "region", "latitude", "longitude"}
+
+	t.nextDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("bom", "tides", "next_height_meters"),
+		"Predicted height of the next tide in meters.",
+		append(portLabels, "type"), labels)
+
+	t.timeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("bom", "tides", "next_time_seconds"),
+		"Predicted time of the next tide, in seconds since the epoch.",
+		append(portLabels, "type"), labels)
+
+	t.heightDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("bom", "tides", "height_meters"),
+		"Tide height in meters at scrape time, interpolated between predictions.",
+		portLabels, labels)
+
+	return &t
+}
+
+// portValues returns the values of the common port labels.
+func (t *Tides) portValues(port *schema.Port) []string {
+	return []string{
+		port.Aac,
+		port.Description,
+		t.product.Amoc.Source.Region,
+		fmt.Sprintf("%f", port.Latitude),
+		fmt.Sprintf("%f", port.Longitude)}
+}
+
+// processPort sends the next predicted tides of a port. They change as each
+// tide passes, so like the interpolated height they are sampled at scrape time
+// rather than timestamped with the issue time.
+func (t *Tides) processPort(port *schema.Port, now time.Time, ch chan<- prometheus.Metric) {
+	for _, tideType := range tideTypes {
+		next := port.Next(tideType, now)
+		if next == nil {
+			continue
+		}
+
+		q, err := next.Quantity()
+		if err == nil {
+			q, err = q.Convert(schema.UnitMeters)
+		}
+		if err != nil {
+			log.Warnf("Skipping %s tide at '%s': %s", tideType, port.Description, err)
+			continue
+		}
+
+		log.Debugf("%s: next %s tide %.2fm at %s", port.Description, tideType, q.Value, time.Time(next.TimeUTC))
+		ch <- prometheus.MustNewConstMetric(t.nextDesc, prometheus.GaugeValue, q.Value,
+			append(t.portValues(port), tideType)...)
+		ch <- prometheus.MustNewConstMetric(t.timeDesc, prometheus.GaugeValue, float64(time.Time(next.TimeUTC).Unix()),
+			append(t.portValues(port), tideType)...)
+	}
+
+	if !t.interpolate {
+		return
+	}
+
+	h, ok := port.HeightAt(now)
+	if !ok {
+		return
+	}
+	h, err := h.Convert(schema.UnitMeters)
+	if err != nil {
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(t.heightDesc, prometheus.GaugeValue, h.Value, t.portValues(port)...)
+}
+
+// Describe implements the Prometheus Collector interface.
+func (t *Tides) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(t, ch)
+}
+
+// Collect implements the Prometheus Collector interface.
+func (t *Tides) Collect(ch chan<- prometheus.Metric) {
+	now := t.now()
+	for i := range t.product.Tides.Port {
+		t.processPort(&t.product.Tides.Port[i], now, ch)
+	}
+}
@@ -0,0 +1,88 @@
+package tides
+
+import (
+	"github.com/gkoh/bom_exporter/bom/schema/schematest"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCollector(t *testing.T) {
+	p := schematest.ParseFile(t, "../schema/tides.xml")
+	if p.Tides == nil || len(p.Tides.Port) != 2 {
+		t.Fatalf("Failed to unmarshal tides: %+v", p.Tides)
+	}
+
+	c := NewWithInterpolation(p)
+	// Between the Outer Harbor morning high and afternoon low.
+	c.now = func() time.Time { return time.Date(2022, time.June, 4, 3, 23, 0, 0, time.UTC) }
+
+	outer := `aac="SA_TP001",description="Outer Harbor",identifier="IDS00000",latitude="-34.779701",longitude="138.480804",region="South Australia"`
+	lincoln := `aac="SA_TP002",description="Port Lincoln",identifier="IDS00000",latitude="-34.718300",longitude="135.869995",region="South Australia"`
+	expected := `
+# HELP bom_tides_height_meters Tide height in meters at scrape time, interpolated between predictions.
+# TYPE bom_tides_height_meters gauge
+bom_tides_height_meters{` + outer + `} 1.6450000000000002
+bom_tides_height_meters{` + lincoln + `} 1.2465234765808793
+# HELP bom_tides_next_height_meters Predicted height of the next tide in meters.
+# TYPE bom_tides_next_height_meters gauge
+bom_tides_next_height_meters{` + outer + `,type="high"} 1.97
+bom_tides_next_height_meters{` + outer + `,type="low"} 0.88
+bom_tides_next_height_meters{` + lincoln + `,type="high"} 1.62
+bom_tides_next_height_meters{` + lincoln + `,type="low"} 0.75
+# HELP bom_tides_next_time_seconds Predicted time of the next tide, in seconds since the epoch.
+# TYPE bom_tides_next_time_seconds gauge
+bom_tides_next_time_seconds{` + outer + `,type="high"} 1.65434532e+09
+bom_tides_next_time_seconds{` + outer + `,type="low"} 1.6543245e+09
+bom_tides_next_time_seconds{` + lincoln + `,type="high"} 1.6543218e+09
+bom_tides_next_time_seconds{` + lincoln + `,type="low"} 1.65434238e+09
+`
+
+	err := testutil.CollectAndCompare(c, strings.NewReader(expected), MetricNames...)
+	if err != nil {
+		t.Errorf("Unexpected metrics: %s", err)
+	}
+
+	problems, err := testutil.CollectAndLint(c, MetricNames...)
+	if err != nil {
+		t.Errorf("CollectAndLint failed: %v", err)
+	}
+	if len(problems) > 0 {
+		t.Errorf("Problems found: %v", problems)
+	}
+
+	// Without interpolation, and after the last prediction, nothing is
+	// exported.
+	n := New(p)
+	n.now = func() time.Time { return time.Date(2022, time.June, 5, 0, 0, 0, 0, time.UTC) }
+	if count := testutil.CollectAndCount(n, MetricNames...); count != 0 {
+		t.Errorf("Got %d metrics after the last prediction, expected 0", count)
+	}
+}
+
+func TestHeightAt(t *testing.T) {
+	p := schematest.ParseFile(t, "../schema/tides.xml")
+	port := &p.Tides.Port[0]
+
+	low := time.Time(port.Tide[0].TimeUTC)
+	high := time.Time(port.Tide[1].TimeUTC)
+
+	inputs := []struct {
+		at       time.Time
+		expected float64
+		ok       bool
+	}{
+		{low, 0.62, true},
+		{low.Add(high.Sub(low) / 2), (0.62 + 2.41) / 2, true},
+		{high, 2.41, true},
+		{low.Add(-time.Minute), 0, false},
+	}
+
+	for _, x := range inputs {
+		h, ok := port.HeightAt(x.at)
+		if ok != x.ok || (ok && (h.Value < x.expected-1e-9 || h.Value > x.expected+1e-9)) {
+			t.Errorf("At %s got %f (%v), expected %f (%v)", x.at, h.Value, ok, x.expected, x.ok)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package validate
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/gkoh/bom_exporter/bom/forecast"
+	"github.com/gkoh/bom_exporter/bom/marine"
+	"github.com/gkoh/bom_exporter/bom/observations"
+	"github.com/gkoh/bom_exporter/bom/schema"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Report summarises how a product is decoded by the schema and collectors.
+type Report struct {
+	Identifier  string
+	ProductType string
+	Version     string
+	// SchemaVersion is the version detected by the schema package.
+	SchemaVersion string
+	Stations      int
+	Areas         int
+	Periods       int
+	// BadTimes lists time fields that are not valid RFC3339.
+	BadTimes []string
+	// UnmappedElements counts element types not decoded by a collector.
+	UnmappedElements map[string]int
+	// UnmappedTexts counts text types not decoded by a collector.
+	UnmappedTexts map[string]int
+	// StrictProblems lists the reasons the product would be rejected in
+	// strict mode.
+	StrictProblems []schema.Problem
+	// Errors lists problems that prevent the product being exported.
+	Errors []string
+}
+
+// Validate decodes data as a product and reports its contents, along with
+// anything the collectors would not export.
+func Validate(data []byte) *Report {
+	r := Report{UnmappedElements: map[string]int{}, UnmappedTexts: map[string]int{}}
+
+	r.BadTimes = badTimes(data)
+	if len(r.BadTimes) > 0 {
+		r.Errors = append(r.Errors, fmt.Sprintf("%d unparseable time fields", len(r.BadTimes)))
+	}
+
+	var p schema.Product
+	err := p.Parse(data)
+	if err != nil {
+		r.Errors = append(r.Errors, fmt.Sprintf("Failed to parse: %s", err))
+		return &r
+	}
+
+	r.Identifier = p.Amoc.Identifier
+	r.ProductType = p.Amoc.ProductType
+	r.Version = p.Version
+	r.SchemaVersion = p.SchemaVersion
+
+	var ve *schema.ValidationError
+	if errors.As(p.Validate(), &ve) {
+		r.StrictProblems = ve.Problems
+	}
+
+	if p.Forecast != nil {
+		r.checkForecast(p.Forecast)
+	} else if p.Observations != nil {
+		r.checkObservations(p.Observations)
+	} else if p.Warning != nil {
+		r.Areas = len(p.Warning.Area)
+	} else if p.Tides != nil {
+		r.Stations = len(p.Tides.Port)
+	} else {
+		r.Errors = append(r.Errors, "No forecast, observations, warning or tides to decode")
+	}
+
+	return &r
+}
+
+func (r *Report) checkForecast(f *schema.Forecast) {
+	r.Areas = len(f.Area)
+	for _, a := range f.Area {
+		r.Periods += len(a.Period)
+		for _, p := range a.Period {
+			for _, e := range p.Elements {
+				if !forecast.DecodesElement(e.Type) {
+					r.UnmappedElements[e.Type]++
+				}
+			}
+			for _, t := range p.Texts {
+				if !forecast.DecodesText(t.Type) && !marine.DecodesText(t.Type) {
+					r.UnmappedTexts[t.Type]++
+				}
+			}
+		}
+	}
+}
+
+func (r *Report) checkObservations(o *schema.Observations) {
+	r.Stations = len(o.Station)
+	for _, s := range o.Station {
+		r.Periods += len(s.Period)
+		for _, p := range s.Period {
+			for _, l := range p.Level {
+				for _, e := range l.Element {
+					if !observations.DecodesElement(e.Type) {
+						r.UnmappedElements[e.Type]++
+					}
+				}
+			}
+		}
+	}
+}
+
+// isTimeName reports whether an element or attribute name holds a timestamp.
+func isTimeName(name string) bool {
+	return strings.HasSuffix(name, "time-utc") ||
+		strings.HasSuffix(name, "time-local") ||
+		strings.HasSuffix(name, "-time")
+}
+
+// badTimes scans the raw XML for time fields that are not valid RFC3339,
+// which would otherwise abort the whole decode with little context.
+func badTimes(data []byte) []string {
+	var bad []string
+
+	check := func(line int, name string, value string) {
+		_, err := time.Parse(time.RFC3339, strings.TrimSpace(value))
+		if err != nil {
+			bad = append(bad, fmt.Sprintf("line %d: %s '%s'", line, name, value))
+		}
+	}
+
+	d := xml.NewDecoder(bytes.NewReader(data))
+	var name string
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			break
+		}
+		line, _ := d.InputPos()
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			name = ""
+			for _, a := range t.Attr {
+				if isTimeName(a.Name.Local) {
+					check(line, t.Name.Local+"@"+a.Name.Local, a.Value)
+				}
+			}
+			if isTimeName(t.Name.Local) {
+				name = t.Name.Local
+			}
+		case xml.CharData:
+			if name != "" {
+				check(line, name, string(t))
+				name = ""
+			}
+		case xml.EndElement:
+			name = ""
+		}
+	}
+
+	return bad
+}
+
+// Failed reports whether the product has hard errors.
+func (r *Report) Failed() bool {
+	return len(r.Errors) > 0
+}
+
+func writeCounts(w io.Writer, title string, counts map[string]int) {
+	if len(counts) == 0 {
+		return
+	}
+
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	fmt.Fprintf(w, "  %s:\n", title)
+	for _, k := range keys {
+		fmt.Fprintf(w, "    %s (%d)\n", k, counts[k])
+	}
+}
+
+// Write prints a human readable form of the report.
+func (r *Report) Write(w io.Writer) {
+	fmt.Fprintf(w, "  identifier: %s\n", r.Identifier)
+	fmt.Fprintf(w, "  product type: %s\n", r.ProductType)
+	fmt.Fprintf(w, "  version: %s (schema %s)\n", r.Version, r.SchemaVersion)
+	fmt.Fprintf(w, "  stations: %d, areas: %d, periods: %d\n", r.Stations, r.Areas, r.Periods)
+
+	if len(r.BadTimes) > 0 {
+		fmt.Fprintf(w, "  unparseable times:\n")
+		for _, t := range r.BadTimes {
+			fmt.Fprintf(w, "    %s\n", t)
+		}
+	}
+
+	if len(r.StrictProblems) > 0 {
+		fmt.Fprintf(w, "  strict mode problems:\n")
+		for _, p := range r.StrictProblems {
+			fmt.Fprintf(w, "    %s: %s\n", p.Field, p.Message)
+		}
+	}
+
+	writeCounts(w, "unmapped elements", r.UnmappedElements)
+	writeCounts(w, "unmapped texts", r.UnmappedTexts)
+
+	for _, e := range r.Errors {
+		fmt.Fprintf(w, "  error: %s\n", e)
+	}
+}
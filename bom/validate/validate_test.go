@@ -0,0 +1,95 @@
+package validate
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	inputs := []struct {
+		file     string
+		version  string
+		stations int
+		areas    int
+		unmapped string
+	}{
+		{file: "../schema/IDS10034.xml", version: "1.7", areas: 7},
+		{file: "../schema/IDS10044.xml", version: "1.7", areas: 75},
+		{file: "../schema/IDS60920.xml", version: "v1.7.1", stations: 81, unmapped: "trend_pres"},
+	}
+
+	for _, x := range inputs {
+		data, err := os.ReadFile(x.file)
+		if err != nil {
+			t.Fatalf("Failed to open '%s': %s", x.file, err)
+		}
+
+		r := Validate(data)
+		if r.Failed() {
+			t.Errorf("'%s' failed: %v", x.file, r.Errors)
+		}
+		if r.Version != x.version {
+			t.Errorf("'%s' version %s, expected %s", x.file, r.Version, x.version)
+		}
+		if len(r.StrictProblems) > 0 {
+			t.Errorf("'%s' has strict mode problems: %v", x.file, r.StrictProblems)
+		}
+		if r.Stations != x.stations || r.Areas != x.areas {
+			t.Errorf("'%s' has %d stations, %d areas, expected %d, %d", x.file, r.Stations, r.Areas, x.stations, x.areas)
+		}
+		if x.unmapped == "" {
+			if len(r.UnmappedElements) > 0 || len(r.UnmappedTexts) > 0 {
+				t.Errorf("'%s' expected no unmapped types, have %v %v", x.file, r.UnmappedElements, r.UnmappedTexts)
+			}
+			continue
+		}
+		if r.UnmappedElements[x.unmapped] == 0 && r.UnmappedTexts[x.unmapped] == 0 {
+			t.Errorf("'%s' expected '%s' to be unmapped", x.file, x.unmapped)
+		}
+
+		var b bytes.Buffer
+		r.Write(&b)
+		if !strings.Contains(b.String(), x.unmapped) {
+			t.Errorf("'%s' report missing '%s':\n%s", x.file, x.unmapped, b.String())
+		}
+	}
+}
+
+func TestValidateErrors(t *testing.T) {
+	inputs := []struct {
+		data     string
+		badTimes int
+	}{
+		{`<product><amoc><issue-time-utc>yesterday</issue-time-utc></amoc></product>`, 1},
+		{`<product><observations><station><period time-utc="2022-13-01T00:00:00Z"/></station></observations></product>`, 1},
+		{`<product><amoc><identifier>IDX</identifier></amoc></product>`, 0},
+		{`not xml`, 0},
+	}
+
+	for _, x := range inputs {
+		r := Validate([]byte(x.data))
+		if !r.Failed() {
+			t.Errorf("Expected '%s' to fail", x.data)
+		}
+		if len(r.BadTimes) != x.badTimes {
+			t.Errorf("Got %d bad times for '%s', expected %d", len(r.BadTimes), x.data, x.badTimes)
+		}
+	}
+}
+
+func TestValidateStrict(t *testing.T) {
+	data, err := os.ReadFile("../connection/test.xml")
+	if err != nil {
+		t.Fatalf("Failed to open 'test.xml': %s", err)
+	}
+
+	r := Validate(data)
+	if r.Failed() {
+		t.Errorf("'test.xml' failed: %v", r.Errors)
+	}
+	if len(r.StrictProblems) == 0 {
+		t.Errorf("Expected strict mode problems for 'test.xml'")
+	}
+}
@@ -0,0 +1,138 @@
+package warnings
+
+import (
+	"github.com/gkoh/bom_exporter/bom/schema"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"strings"
+	"time"
+)
+
+// MetricNames is the list of metrics exported by the warnings collector.
+var MetricNames = []string{
+	"bom_warnings_area",
+	"bom_warnings_info",
+	"bom_warnings_issue_time_seconds",
+	"bom_warnings_expiry_time_seconds",
+}
+
+// Warnings combines the unmarshalled warning data and the corresponding
+// Prometheus output metrics.
+type Warnings struct {
+	product    *schema.Product
+	now        func() time.Time
+	areaDesc   *prometheus.Desc
+	infoDesc   *prometheus.Desc
+	issueDesc  *prometheus.Desc
+	expiryDesc *prometheus.Desc
+}
+
+// New creates a warnings collector based on an unmarshalled Product.
+func New(product *schema.Product) *Warnings {
+	var w Warnings
+
+	w.product = product
+	w.now = time.Now
+
+	labels := prometheus.Labels{"identifier": product.Amoc.Identifier}
+
+	w.areaDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("bom", "warnings", "area"),
+		"Warning covering an area, 1 while active and 0 once cancelled or expired.",
+		[]string{"aac", "parent_aac", "description", "region", "area_type", "warning_type", "phenomenon", "severity", "phase"}, labels)
+
+	w.infoDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("bom", "warnings", "info"),
+		"Warning metadata, value is always 1.",
+		[]string{"region", "warning_type", "phase", "incident_id", "headline"}, labels)
+
+	w.issueDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("bom", "warnings", "issue_time_seconds"),
+		"Time the warning was issued, in seconds since the epoch.",
+		[]string{"region", "warning_type"}, labels)
+
+	w.expiryDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("bom", "warnings", "expiry_time_seconds"),
+		"Time the warning expires, in seconds since the epoch.",
+		[]string{"region", "warning_type"}, labels)
+
+	return &w
+}
+
+// phase returns the phase of an area, defaulting to that of the warning.
+func (w *Warnings) phase(area *schema.WarningArea) string {
+	if area.Phase != "" {
+		return strings.ToUpper(area.Phase)
+	}
+	return strings.ToUpper(w.product.Amoc.Phase)
+}
+
+// active reports whether the warning is in force for an area.
+func (w *Warnings) active(area *schema.WarningArea) bool {
+	return w.phase(area) != "CAN" && w.product.Amoc.ValidAt(w.now())
+}
+
+func (w *Warnings) processArea(area *schema.WarningArea, ch chan<- prometheus.Metric) {
+	p := w.product
+	region := p.Amoc.Source.Region
+
+	phenomena := area.Phenomena
+	if len(phenomena) == 0 {
+		phenomena = p.Warning.Info.Phenomena
+	}
+	if len(phenomena) == 0 {
+		phenomena = []schema.Phenomenon{{}}
+	}
+
+	v := 0.0
+	if w.active(area) {
+		v = 1.0
+	}
+
+	// The value changes once the warning expires, so it is sampled at scrape
+	// time rather than timestamped with the issue time.
+	for _, ph := range phenomena {
+		log.Debugf("%s (%s): %s %s %s", area.Description, area.Aac, ph.Type, ph.Severity, w.phase(area))
+		ch <- prometheus.MustNewConstMetric(w.areaDesc, prometheus.GaugeValue, v,
+			area.Aac,
+			area.ParentAac,
+			area.Description,
+			region,
+			area.Type,
+			p.Warning.Info.Type,
+			ph.Type,
+			ph.Severity,
+			w.phase(area))
+	}
+}
+
+// Describe implements the Prometheus Collector interface.
+func (w *Warnings) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(w, ch)
+}
+
+// Collect implements the Prometheus Collector interface.
+func (w *Warnings) Collect(ch chan<- prometheus.Metric) {
+	p := w.product
+	region := p.Amoc.Source.Region
+	issued := time.Time(p.Amoc.IssueTimeUTC)
+	warningType := p.Warning.Info.Type
+
+	ch <- prometheus.NewMetricWithTimestamp(issued,
+		prometheus.MustNewConstMetric(w.infoDesc, prometheus.GaugeValue, 1.0,
+			region, warningType, strings.ToUpper(p.Amoc.Phase), p.Amoc.IncidentID, p.Warning.Info.Headline))
+
+	ch <- prometheus.NewMetricWithTimestamp(issued,
+		prometheus.MustNewConstMetric(w.issueDesc, prometheus.GaugeValue, float64(issued.Unix()),
+			region, warningType))
+
+	if expiry := time.Time(p.Amoc.ExpiryTime); !expiry.IsZero() {
+		ch <- prometheus.NewMetricWithTimestamp(issued,
+			prometheus.MustNewConstMetric(w.expiryDesc, prometheus.GaugeValue, float64(expiry.Unix()),
+				region, warningType))
+	}
+
+	for i := range p.Warning.Area {
+		w.processArea(&p.Warning.Area[i], ch)
+	}
+}
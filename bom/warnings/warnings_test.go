@@ -0,0 +1,84 @@
+package warnings
+
+import (
+	"github.com/gkoh/bom_exporter/bom/schema"
+	"github.com/gkoh/bom_exporter/bom/schema/schematest"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCollector(t *testing.T) {
+	p := schematest.ParseFile(t, "../schema/IDS21037.xml")
+	if p.Warning == nil {
+		t.Fatalf("Failed to unmarshal warning")
+	}
+
+	w := New(p)
+	w.now = func() time.Time { return time.Date(2022, time.June, 4, 5, 0, 0, 0, time.UTC) }
+
+	expected := `
+# HELP bom_warnings_area Warning covering an area, 1 while active and 0 once cancelled or expired.
+# TYPE bom_warnings_area gauge
+bom_warnings_area{aac="SA_PW001",area_type="public-district",description="Adelaide Metropolitan",identifier="IDS21037",parent_aac="",phase="UPD",phenomenon="damaging_wind",region="South Australia",severity="severe",warning_type="severe_weather"} 1
+bom_warnings_area{aac="SA_PW003",area_type="public-district",description="Mid North",identifier="IDS21037",parent_aac="",phase="CAN",phenomenon="damaging_wind",region="South Australia",severity="severe",warning_type="severe_weather"} 0
+bom_warnings_area{aac="SA_PW007",area_type="public-district",description="Mount Lofty Ranges",identifier="IDS21037",parent_aac="",phase="UPD",phenomenon="damaging_wind",region="South Australia",severity="severe",warning_type="severe_weather"} 1
+bom_warnings_area{aac="SA_PW007",area_type="public-district",description="Mount Lofty Ranges",identifier="IDS21037",parent_aac="",phase="UPD",phenomenon="heavy_rain",region="South Australia",severity="severe",warning_type="severe_weather"} 1
+# HELP bom_warnings_expiry_time_seconds Time the warning expires, in seconds since the epoch.
+# TYPE bom_warnings_expiry_time_seconds gauge
+bom_warnings_expiry_time_seconds{identifier="IDS21037",region="South Australia",warning_type="severe_weather"} 1.6543392e+09 1654317600000
+# HELP bom_warnings_info Warning metadata, value is always 1.
+# TYPE bom_warnings_info gauge
+bom_warnings_info{headline="Severe Weather Warning for DAMAGING WINDS for people in Adelaide Metropolitan and Mount Lofty Ranges",identifier="IDS21037",incident_id="IDS21037-20220604-0002",phase="UPD",region="South Australia",warning_type="severe_weather"} 1 1654317600000
+# HELP bom_warnings_issue_time_seconds Time the warning was issued, in seconds since the epoch.
+# TYPE bom_warnings_issue_time_seconds gauge
+bom_warnings_issue_time_seconds{identifier="IDS21037",region="South Australia",warning_type="severe_weather"} 1.6543176e+09 1654317600000
+`
+
+	err := testutil.CollectAndCompare(w, strings.NewReader(expected), MetricNames...)
+	if err != nil {
+		t.Errorf("Unexpected metrics: %s", err)
+	}
+
+	problems, err := testutil.CollectAndLint(w, MetricNames...)
+	if err != nil {
+		t.Errorf("CollectAndLint failed: %v", err)
+	}
+	if len(problems) > 0 {
+		t.Errorf("Problems found: %v", problems)
+	}
+
+	// Once expired no area is active.
+	w.now = func() time.Time { return time.Date(2022, time.June, 4, 11, 0, 0, 0, time.UTC) }
+	count := testutil.CollectAndCount(w, "bom_warnings_area")
+	if count != 4 {
+		t.Errorf("Got %d area metrics, expected 4", count)
+	}
+	for _, a := range p.Warning.Area {
+		if w.active(&a) {
+			t.Errorf("Area '%s' active after expiry", a.Description)
+		}
+	}
+}
+
+func TestPhase(t *testing.T) {
+	p := schema.Product{
+		Amoc:    schema.Amoc{Identifier: "a5a5a5a5", Phase: "new"},
+		Warning: &schema.Warning{Area: []schema.WarningArea{{Aac: "bart"}, {Aac: "lisa", Phase: "can"}}},
+	}
+
+	w := New(&p)
+	if w.phase(&p.Warning.Area[0]) != "NEW" || !w.active(&p.Warning.Area[0]) {
+		t.Errorf("Expected area without phase to be NEW and active")
+	}
+	if w.phase(&p.Warning.Area[1]) != "CAN" || w.active(&p.Warning.Area[1]) {
+		t.Errorf("Expected cancelled area to be inactive")
+	}
+
+	// Without phenomena a single series is still exported per area.
+	count := testutil.CollectAndCount(w, "bom_warnings_area")
+	if count != 2 {
+		t.Errorf("Got %d area metrics, expected 2", count)
+	}
+}
@@ -1,21 +1,223 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/gkoh/bom_exporter/bom"
+	"github.com/gkoh/bom_exporter/bom/cap"
+	"github.com/gkoh/bom_exporter/bom/connection"
 	"github.com/gkoh/bom_exporter/bom/connection/ftp"
+	"github.com/gkoh/bom_exporter/bom/forecast"
+	"github.com/gkoh/bom_exporter/bom/offline"
+	"github.com/gkoh/bom_exporter/bom/schema"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/exporter-toolkit/web"
 	log "github.com/sirupsen/logrus"
+	"log/slog"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 )
 
 var requestDurations prometheus.Histogram
 
+var (
+	listenAddress   = flag.String("web.listen-address", defaultListenAddress(), "Address on which to expose metrics, defaults to :$PORT if PORT is set.")
+	offlineDir      = flag.String("offline.dir", "", "Serve products from <dir>/<id>.xml instead of the BoM FTP server.")
+	strict          = flag.Bool("schema.strict", false, "Reject products with unknown schema versions or missing mandatory fields.")
+	baseUnits       = flag.Bool("metrics.base-units", false, "Export each quantity once in its base unit, named in the metric name (eg. _meters_per_second), instead of with a units label.")
+	tideHeight      = flag.Bool("tides.interpolate", false, "Also export the tide height at scrape time, interpolated between predictions.")
+	streaming       = flag.Bool("schema.streaming", false, "Decode products incrementally as they are read while collecting each scrape, never holding a whole product in memory.")
+	ftpAddress      = flag.String("ftp.address", ftp.DefaultAddress, "host:port of the BoM FTP server.")
+	upstreamTimeout = flag.Duration("ftp.check-timeout", 10*time.Second, "Timeout for the /-/upstream connectivity check.")
+	webConfigFile   = flag.String("web.config.file", "", "Path to a Prometheus web configuration file enabling TLS and/or basic authentication.")
+	shutdownTimeout = flag.Duration("web.shutdown-timeout", 30*time.Second, "Time allowed for in-flight requests to complete on shutdown.")
+)
+
+// defaultListenAddress returns the listen address used before
+// --web.listen-address existed, :$PORT if PORT is set, otherwise :8080.
+func defaultListenAddress() string {
+	if port := os.Getenv("PORT"); port != "" {
+		return ":" + port
+	}
+	return ":8080"
+}
+
+// pointList is a repeatable list of points of interest given as name=lat,lon.
+type pointList []cap.PointOfInterest
+
+func (l *pointList) String() string {
+	var s []string
+	for _, p := range *l {
+		s = append(s, fmt.Sprintf("%s=%g,%g", p.Name, p.Latitude, p.Longitude))
+	}
+	return strings.Join(s, " ")
+}
+
+func (l *pointList) Set(v string) error {
+	p, err := cap.ParsePointOfInterest(v)
+	if err != nil {
+		return err
+	}
+	*l = append(*l, p)
+	return nil
+}
+
+// points are tested against the polygons of CAP alerts.
+var points pointList
+
+// ready is set once the exporter has finished starting up.
+var ready atomic.Bool
+
+// products holds the offline product store once it has been loaded.
+var products atomic.Pointer[offline.Store]
+
+// retainedTexts is the number of products whose texts are kept for /texts.
+const retainedTexts = 64
+
+// textCache holds the long form forecast texts of the products most recently
+// scraped. Only the texts are kept, for at most size products, dropping the
+// product least recently stored first.
+type textCache struct {
+	sync.Mutex
+	size  int
+	ids   []string
+	texts map[string][]forecast.Text
+}
+
+// newTextCache creates a textCache holding the texts of up to size products.
+func newTextCache(size int) *textCache {
+	return &textCache{size: size, texts: make(map[string][]forecast.Text)}
+}
+
+// Store keeps the texts of the product id, replacing any kept before. A
+// product without texts is not kept.
+func (c *textCache) Store(id string, texts []forecast.Text) {
+	c.Lock()
+	defer c.Unlock()
+
+	if i := slices.Index(c.ids, id); i >= 0 {
+		c.ids = slices.Delete(c.ids, i, i+1)
+		delete(c.texts, id)
+	}
+	if len(texts) == 0 {
+		return
+	}
+
+	if len(c.ids) >= c.size {
+		delete(c.texts, c.ids[0])
+		c.ids = slices.Delete(c.ids, 0, 1)
+	}
+	c.ids = append(c.ids, id)
+	c.texts[id] = texts
+}
+
+// Load returns the texts kept for the product id.
+func (c *textCache) Load(id string) ([]forecast.Text, bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	texts, ok := c.texts[id]
+	return texts, ok
+}
+
+// fetched holds the texts of the retainedTexts products most recently scraped
+// from the BoM FTP server, so the texts exported by a scrape can be served
+// without fetching again.
+var fetched = newTextCache(retainedTexts)
+
+// product returns the parsed product for id, from the offline store if
+// configured, otherwise freshly retrieved from the BoM FTP server.
+func product(id string) (*bom.Metric, error) {
+	if *offlineDir != "" {
+		s := products.Load()
+		if s == nil {
+			return nil, fmt.Errorf("Offline products not loaded yet")
+		}
+		return s.Get(id)
+	}
+
+	m := newMetric(ftp.NewWithAddress(*ftpAddress, id))
+	err := m.RetrieveAndParse()
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// lastTexts returns the texts of the product id as last scraped, retrieving
+// the product only if its texts are not kept.
+func lastTexts(id string) ([]forecast.Text, error) {
+	if *offlineDir == "" {
+		if texts, ok := fetched.Load(id); ok {
+			return texts, nil
+		}
+	}
+
+	m, err := product(id)
+	if err != nil {
+		return nil, err
+	}
+	defer m.Close()
+
+	texts := m.Texts()
+	if *offlineDir == "" {
+		fetched.Store(id, texts)
+	}
+	return texts, nil
+}
+
+// metricFlags are the flags used by newMetric, shared by the server and the
+// fetch subcommand.
+var metricFlags = []string{"schema.strict", "schema.streaming", "metrics.base-units", "tides.interpolate", "cap.point"}
+
+// addMetricFlags adds the metric flags to fs, setting the same values as the
+// server flags.
+func addMetricFlags(fs *flag.FlagSet) {
+	for _, name := range metricFlags {
+		f := flag.Lookup(name)
+		fs.Var(f.Value, f.Name, f.Usage)
+	}
+}
+
+// newMetric creates a Metric for r as selected by the flags.
+func newMetric(r connection.Retriever) *bom.Metric {
+	m := bom.NewWithMode(r, schemaMode())
+	if *streaming {
+		m = bom.NewStreamingWithMode(r, schemaMode())
+	}
+	if *baseUnits {
+		m.WithBaseUnits()
+	}
+	if *tideHeight {
+		m.WithTideInterpolation()
+	}
+	return m.WithPointsOfInterest(points...)
+}
+
+// schemaMode returns the product validation mode selected by the flags.
+func schemaMode() schema.Mode {
+	if *strict {
+		return schema.Strict
+	}
+	return schema.Lenient
+}
+
 func metricsHandler() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var h http.Handler
+		var m *bom.Metric
 
 		timer := prometheus.NewTimer(requestDurations)
 		defer timer.ObserveDuration()
@@ -26,24 +228,156 @@ func metricsHandler() gin.HandlerFunc {
 		} else {
 			registry := prometheus.NewPedanticRegistry()
 
-			m := bom.New(ftp.New(id))
-
-			err := m.RetrieveAndParse()
+			var err error
+			m, err = product(id)
+			var ve *schema.ValidationError
+			if errors.As(err, &ve) {
+				log.Warnf("Failed to validate: %s", err)
+				c.JSON(http.StatusUnprocessableEntity, gin.H{"error": fmt.Sprintf("'%s' failed validation.", id), "validation": ve})
+				return
+			}
 			if err != nil {
 				log.Warnf("Failed to process: %s", err)
 				c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("'%s' not found.", id)})
 				return
 			}
+			defer m.Close()
 			registry.MustRegister(m)
 
 			h = promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
 		}
 
 		h.ServeHTTP(c.Writer, c.Request)
+
+		if m != nil && *offlineDir == "" {
+			fetched.Store(id, m.Texts())
+		}
 	}
 }
 
+// textsHandler serves the long form forecast texts of a product, optionally
+// only those with the given hash, as exported by bom_forecast_text. Texts are
+// served from the texts kept from the latest scrape by /metrics, so they match
+// its hashes.
+func textsHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Query("id")
+		if id == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Missing product 'id'."})
+			return
+		}
+
+		all, err := lastTexts(id)
+		if err != nil {
+			log.Warnf("Failed to process: %s", err)
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("'%s' not found.", id)})
+			return
+		}
+
+		texts := []forecast.Text{}
+		hash := c.Query("hash")
+		for _, t := range all {
+			if hash == "" || t.Hash == hash {
+				texts = append(texts, t)
+			}
+		}
+
+		if hash != "" && len(texts) == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Text '%s' not found in '%s'.", hash, id)})
+			return
+		}
+
+		c.JSON(http.StatusOK, texts)
+	}
+}
+
+func healthyHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.String(http.StatusOK, "Healthy.\n")
+	}
+}
+
+func readyHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !ready.Load() {
+			c.String(http.StatusServiceUnavailable, "Not ready.\n")
+			return
+		}
+		c.String(http.StatusOK, "Ready.\n")
+	}
+}
+
+func upstreamHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		stages := ftp.Check(*ftpAddress, *upstreamTimeout)
+
+		success := true
+		for _, s := range stages {
+			success = success && s.Success
+		}
+
+		status := http.StatusOK
+		if !success {
+			status = http.StatusServiceUnavailable
+		}
+
+		c.JSON(status, gin.H{"address": *ftpAddress, "success": success, "stages": stages})
+	}
+}
+
+func newRouter() *gin.Engine {
+	r := gin.Default()
+	r.SetTrustedProxies(nil)
+
+	r.GET("/metrics", metricsHandler())
+	r.GET("/texts", textsHandler())
+	r.GET("/-/healthy", healthyHandler())
+	r.GET("/-/ready", readyHandler())
+	r.GET("/-/upstream", upstreamHandler())
+
+	return r
+}
+
+// serve runs srv on l, with TLS and basic authentication from the optional
+// web configuration file, until ctx is cancelled. It then stops accepting new
+// connections and waits up to timeout for in-flight requests to complete.
+func serve(ctx context.Context, srv *http.Server, l net.Listener, webConfig string, timeout time.Duration) error {
+	flags := web.FlagConfig{WebConfigFile: &webConfig}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- web.Serve(l, srv, &flags, slog.Default())
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Infof("Shutting down, waiting up to %s for in-flight requests", timeout)
+	ready.Store(false)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err := srv.Shutdown(shutdownCtx)
+	if err != nil {
+		log.Warnf("Failed to drain in-flight requests: %s", err)
+		srv.Close()
+		return err
+	}
+
+	err = <-errCh
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
 func init() {
+	flag.Var(&points, "cap.point", "Point of interest tested against CAP alert polygons, as name=lat,lon. May be repeated.")
+
 	gin.SetMode(gin.ReleaseMode)
 	requestDurations = prometheus.NewHistogram(prometheus.HistogramOpts{
 		Namespace: "bom",
@@ -55,10 +389,54 @@ func init() {
 }
 
 func main() {
-	r := gin.Default()
-	r.SetTrustedProxies(nil)
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "fetch":
+			os.Exit(runFetch(os.Args[2:]))
+		case "dump":
+			os.Exit(runDump(os.Args[2:]))
+		case "validate":
+			os.Exit(runValidate(os.Args[2:]))
+		}
+	}
 
-	r.GET("/metrics", metricsHandler())
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	err := web.Validate(*webConfigFile)
+	if err != nil {
+		log.Fatalf("Invalid web configuration '%s': %s", *webConfigFile, err)
+	}
+
+	srv := &http.Server{Handler: newRouter()}
+
+	l, err := net.Listen("tcp", *listenAddress)
+	if err != nil {
+		log.Fatalf("Failed to listen on '%s': %s", *listenAddress, err)
+	}
 
-	r.Run()
+	// Probes are answered while the offline directory is loaded, but the
+	// exporter is not ready until it completes.
+	go func() {
+		if *offlineDir != "" {
+			s, err := offline.NewWithFunc(*offlineDir, newMetric)
+			if err != nil {
+				log.Fatalf("Failed to load offline products from '%s': %s", *offlineDir, err)
+			}
+			products.Store(s)
+		}
+		ready.Store(true)
+	}()
+
+	err = serve(ctx, srv, l, *webConfigFile, *shutdownTimeout)
+
+	if s := products.Load(); s != nil {
+		s.Close()
+	}
+
+	if err != nil {
+		log.Fatalf("Server failed: %s", err)
+	}
 }
@@ -0,0 +1,423 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"github.com/gkoh/bom_exporter/bom"
+	"github.com/gkoh/bom_exporter/bom/connection/file"
+	"github.com/gkoh/bom_exporter/bom/forecast"
+	"github.com/gkoh/bom_exporter/bom/offline"
+	"github.com/gkoh/bom_exporter/bom/schema"
+	"golang.org/x/crypto/bcrypt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHealthEndpoints(t *testing.T) {
+	r := newRouter()
+
+	v := []struct {
+		path     string
+		ready    bool
+		expected int
+	}{
+		{"/-/healthy", false, http.StatusOK},
+		{"/-/ready", false, http.StatusServiceUnavailable},
+		{"/-/ready", true, http.StatusOK},
+	}
+
+	for _, k := range v {
+		ready.Store(k.ready)
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, k.path, nil))
+		if w.Code != k.expected {
+			t.Errorf("GET %s (ready %v) returned %d, expected %d", k.path, k.ready, w.Code, k.expected)
+		}
+	}
+}
+
+func TestDefaultListenAddress(t *testing.T) {
+	t.Setenv("PORT", "")
+	if v := defaultListenAddress(); v != ":8080" {
+		t.Errorf("Expected ':8080', got '%s'", v)
+	}
+
+	t.Setenv("PORT", "9090")
+	if v := defaultListenAddress(); v != ":9090" {
+		t.Errorf("Expected ':9090', got '%s'", v)
+	}
+}
+
+func TestGracefulShutdown(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err)
+	}
+
+	started := make(chan struct{})
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		fmt.Fprint(w, "done")
+	})}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- serve(ctx, srv, l, "", 5*time.Second)
+	}()
+
+	body := make(chan string, 1)
+	go func() {
+		resp, err := http.Get("http://" + l.Addr().String())
+		if err != nil {
+			body <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		body <- string(b)
+	}()
+
+	<-started
+	cancel()
+
+	if b := <-body; b != "done" {
+		t.Errorf("In-flight request was not drained, got '%s'", b)
+	}
+	if err := <-served; err != nil {
+		t.Errorf("serve returned %s", err)
+	}
+	if ready.Load() {
+		t.Errorf("Still ready after shutdown")
+	}
+}
+
+// selfSignedCert writes a self-signed certificate and key for 127.0.0.1 into
+// dir, returning the certificate for use in a client pool.
+func selfSignedCert(t *testing.T, dir string) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %s", err)
+	}
+
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "bom_exporter test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %s", err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %s", err)
+	}
+
+	err = os.WriteFile(filepath.Join(dir, "tls.crt"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	if err != nil {
+		t.Fatalf("Failed to write file: %s", err)
+	}
+	err = os.WriteFile(filepath.Join(dir, "tls.key"), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600)
+	if err != nil {
+		t.Fatalf("Failed to write file: %s", err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %s", err)
+	}
+	return cert
+}
+
+func TestWebConfig(t *testing.T) {
+	dir := t.TempDir()
+	cert := selfSignedCert(t, dir)
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("Failed to hash password: %s", err)
+	}
+
+	config := filepath.Join(dir, "web-config.yml")
+	err = os.WriteFile(config, []byte(fmt.Sprintf(`tls_server_config:
+  cert_file: tls.crt
+  key_file: tls.key
+  min_version: TLS12
+basic_auth_users:
+  prometheus: %s
+`, hash)), 0600)
+	if err != nil {
+		t.Fatalf("Failed to write file: %s", err)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go serve(ctx, &http.Server{Handler: newRouter()}, l, config, time.Second)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+
+	v := []struct {
+		path     string
+		user     string
+		password string
+		expected int
+	}{
+		{"/-/healthy", "", "", http.StatusUnauthorized},
+		{"/-/healthy", "prometheus", "wrong", http.StatusUnauthorized},
+		{"/-/healthy", "prometheus", "secret", http.StatusOK},
+		{"/metrics", "", "", http.StatusUnauthorized},
+		{"/metrics", "prometheus", "secret", http.StatusOK},
+	}
+
+	for _, k := range v {
+		req, _ := http.NewRequest(http.MethodGet, "https://"+l.Addr().String()+k.path, nil)
+		if k.user != "" {
+			req.SetBasicAuth(k.user, k.password)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("GET %s failed: %s", k.path, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != k.expected {
+			t.Errorf("GET %s as '%s' returned %d, expected %d", k.path, k.user, resp.StatusCode, k.expected)
+		}
+	}
+
+	// Plain HTTP must be refused by the TLS listener.
+	resp, err := http.Get("http://" + l.Addr().String() + "/-/healthy")
+	if err == nil && resp.StatusCode == http.StatusOK {
+		t.Errorf("Plain HTTP request succeeded against TLS listener")
+	}
+}
+
+func TestOfflineMetrics(t *testing.T) {
+	dir := t.TempDir()
+	data, err := os.ReadFile("../bom/schema/IDS10034.xml")
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %s", err)
+	}
+	err = os.WriteFile(filepath.Join(dir, "IDS10034.xml"), data, 0644)
+	if err != nil {
+		t.Fatalf("Failed to write file: %s", err)
+	}
+
+	*offlineDir = dir
+	defer func() { *offlineDir = "" }()
+
+	r := newRouter()
+
+	// Not loaded yet.
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics?id=IDS10034", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Got %d before loading, expected %d", w.Code, http.StatusNotFound)
+	}
+
+	s, err := offline.New(dir, schema.Lenient)
+	if err != nil {
+		t.Fatalf("Failed to load '%s': %s", dir, err)
+	}
+	defer s.Close()
+	products.Store(s)
+	defer products.Store(nil)
+
+	v := []struct {
+		id       string
+		expected int
+	}{
+		{"IDS10034", http.StatusOK},
+		{"IDS60920", http.StatusNotFound},
+		{"..%2FIDS10034", http.StatusNotFound},
+	}
+
+	for _, k := range v {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics?id="+k.id, nil))
+		if w.Code != k.expected {
+			t.Errorf("GET id=%s returned %d, expected %d", k.id, w.Code, k.expected)
+		}
+	}
+}
+
+func TestTexts(t *testing.T) {
+	dir := t.TempDir()
+	data, err := os.ReadFile("../bom/schema/IDS10034.xml")
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %s", err)
+	}
+	err = os.WriteFile(filepath.Join(dir, "IDS10034.xml"), data, 0644)
+	if err != nil {
+		t.Fatalf("Failed to write file: %s", err)
+	}
+
+	*offlineDir = dir
+	defer func() { *offlineDir = "" }()
+
+	s, err := offline.New(dir, schema.Lenient)
+	if err != nil {
+		t.Fatalf("Failed to load '%s': %s", dir, err)
+	}
+	defer s.Close()
+	products.Store(s)
+	defer products.Store(nil)
+
+	footer := "* Calls to 1300 numbers cost around 27.5c incl. GST, higher from mobiles or public phones."
+
+	v := []struct {
+		query    string
+		expected int
+		texts    int
+	}{
+		{"id=IDS10034", http.StatusOK, 10},
+		{"id=IDS10034&hash=" + forecast.TextHash(footer), http.StatusOK, 1},
+		{"id=IDS10034&hash=0000000000000000", http.StatusNotFound, 0},
+		{"id=IDS60920", http.StatusNotFound, 0},
+		{"", http.StatusBadRequest, 0},
+	}
+
+	r := newRouter()
+	for _, k := range v {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/texts?"+k.query, nil))
+		if w.Code != k.expected {
+			t.Errorf("GET %s returned %d, expected %d", k.query, w.Code, k.expected)
+			continue
+		}
+		if w.Code != http.StatusOK {
+			continue
+		}
+
+		var texts []forecast.Text
+		err := json.Unmarshal(w.Body.Bytes(), &texts)
+		if err != nil {
+			t.Errorf("GET %s returned invalid JSON: %s", k.query, err)
+		}
+		if len(texts) != k.texts {
+			t.Errorf("GET %s returned %d texts, expected %d", k.query, len(texts), k.texts)
+		}
+		if k.texts == 1 && texts[0].Value != footer {
+			t.Errorf("GET %s returned '%s', expected '%s'", k.query, texts[0].Value, footer)
+		}
+	}
+}
+
+func TestTextsLastFetched(t *testing.T) {
+	m := bom.New(file.New("../bom/schema/IDS10034.xml"))
+	err := m.RetrieveAndParse()
+	if err != nil {
+		t.Fatalf("Failed to retrieve and parse: %s", err)
+	}
+
+	// The texts last fetched are served without fetching the product again.
+	fetched.Store("IDS10034", m.Texts())
+	defer fetched.Store("IDS10034", nil)
+
+	w := httptest.NewRecorder()
+	newRouter().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/texts?id=IDS10034", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET returned %d, expected %d", w.Code, http.StatusOK)
+	}
+
+	var texts []forecast.Text
+	err = json.Unmarshal(w.Body.Bytes(), &texts)
+	if err != nil || len(texts) != 10 {
+		t.Errorf("Got %d texts, expected 10: %v", len(texts), err)
+	}
+}
+
+func TestTextCache(t *testing.T) {
+	c := newTextCache(2)
+	text := []forecast.Text{{Hash: "a5a5a5a5", Value: "Fine."}}
+
+	c.Store("IDS10034", text)
+	c.Store("IDS10044", text)
+	c.Store("IDS10034", text)
+	c.Store("IDS10048", text)
+	c.Store("IDS60920", nil)
+
+	v := []struct {
+		id   string
+		kept bool
+	}{
+		{"IDS10034", true},
+		{"IDS10044", false},
+		{"IDS10048", true},
+		{"IDS60920", false},
+	}
+
+	for _, k := range v {
+		if _, ok := c.Load(k.id); ok != k.kept {
+			t.Errorf("'%s' kept %v, expected %v", k.id, ok, k.kept)
+		}
+	}
+	if len(c.ids) != 2 || len(c.texts) != 2 {
+		t.Errorf("Kept %d ids and %d texts, expected 2", len(c.ids), len(c.texts))
+	}
+}
+
+func TestStrictValidationError(t *testing.T) {
+	dir := t.TempDir()
+	data, err := os.ReadFile("../bom/connection/test.xml")
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %s", err)
+	}
+	err = os.WriteFile(filepath.Join(dir, "test.xml"), data, 0644)
+	if err != nil {
+		t.Fatalf("Failed to write file: %s", err)
+	}
+
+	*offlineDir = dir
+	defer func() { *offlineDir = "" }()
+
+	s, err := offline.New(dir, schema.Strict)
+	if err != nil {
+		t.Fatalf("Failed to load '%s': %s", dir, err)
+	}
+	defer s.Close()
+	products.Store(s)
+	defer products.Store(nil)
+
+	w := httptest.NewRecorder()
+	newRouter().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics?id=test", nil))
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Got %d, expected %d", w.Code, http.StatusUnprocessableEntity)
+	}
+
+	var body struct {
+		Validation schema.ValidationError `json:"validation"`
+	}
+	err = json.Unmarshal(w.Body.Bytes(), &body)
+	if err != nil || len(body.Validation.Problems) == 0 {
+		t.Errorf("Expected validation problems in '%s': %v", w.Body.String(), err)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/gkoh/bom_exporter/bom/connection"
+	"github.com/gkoh/bom_exporter/bom/connection/file"
+	"github.com/gkoh/bom_exporter/bom/connection/ftp"
+	"github.com/gkoh/bom_exporter/bom/schema"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"os"
+)
+
+// dump writes each retrieved product to w in the given format. YAML
+// documents are separated with '---', JSON documents are concatenated.
+func dump(w io.Writer, retrievers []connection.Retriever, format schema.Format) error {
+	for i, r := range retrievers {
+		data, err := r.Retrieve()
+		if err != nil {
+			return fmt.Errorf("Failed to retrieve '%s': %w", r.Identifier(), err)
+		}
+
+		var p schema.Product
+		err = p.Parse(data)
+		if err != nil {
+			return fmt.Errorf("Failed to parse '%s': %w", r.Identifier(), err)
+		}
+
+		if format == schema.FormatYAML && i > 0 {
+			fmt.Fprintln(w, "---")
+		}
+
+		err = p.Encode(w, format)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// runDump implements the dump subcommand, returning the process exit code.
+func runDump(args []string) int {
+	fs := flag.NewFlagSet("dump", flag.ExitOnError)
+	var ids idList
+	fs.Var(&ids, "id", "Product identifier to retrieve from the BoM FTP server, may be repeated or comma separated.")
+	format := fs.String("format", string(schema.FormatJSON), "Output format, 'json' or 'yaml'.")
+	address := fs.String("ftp.address", ftp.DefaultAddress, "host:port of the BoM FTP server.")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s dump [--format json|yaml] [--id ID...] [FILE...]\n\n", os.Args[0])
+		fmt.Fprintf(fs.Output(), "Decode products and write them as JSON or YAML.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	var retrievers []connection.Retriever
+	for _, id := range ids {
+		retrievers = append(retrievers, ftp.NewWithAddress(*address, id))
+	}
+	for _, f := range fs.Args() {
+		retrievers = append(retrievers, file.New(f))
+	}
+
+	if len(retrievers) == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	err := dump(os.Stdout, retrievers, schema.Format(*format))
+	if err != nil {
+		log.Error(err)
+		return 1
+	}
+
+	return 0
+}
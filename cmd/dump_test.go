@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/gkoh/bom_exporter/bom/connection"
+	"github.com/gkoh/bom_exporter/bom/connection/file"
+	"github.com/gkoh/bom_exporter/bom/schema"
+	"strings"
+	"testing"
+)
+
+func TestDump(t *testing.T) {
+	retrievers := []connection.Retriever{
+		file.New("../bom/schema/IDS10034.xml"),
+		file.New("../bom/schema/IDS60920.xml"),
+	}
+
+	var b bytes.Buffer
+	err := dump(&b, retrievers, schema.FormatJSON)
+	if err != nil {
+		t.Fatalf("Failed to dump JSON: %s", err)
+	}
+
+	d := json.NewDecoder(&b)
+	for _, id := range []string{"IDS10034", "IDS60920"} {
+		var v struct {
+			Amoc struct {
+				Identifier string `json:"identifier"`
+			} `json:"amoc"`
+		}
+		err = d.Decode(&v)
+		if err != nil || v.Amoc.Identifier != id {
+			t.Errorf("Expected '%s', got '%s': %v", id, v.Amoc.Identifier, err)
+		}
+	}
+
+	b.Reset()
+	err = dump(&b, retrievers, schema.FormatYAML)
+	if err != nil {
+		t.Fatalf("Failed to dump YAML: %s", err)
+	}
+	if strings.Count(b.String(), "\n---\n") != 1 {
+		t.Errorf("Expected 2 YAML documents")
+	}
+
+	err = dump(&b, []connection.Retriever{file.New("missing.xml")}, schema.FormatJSON)
+	if err == nil {
+		t.Errorf("Expected error for missing file")
+	}
+}
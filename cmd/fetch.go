@@ -0,0 +1,129 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/gkoh/bom_exporter/bom/connection"
+	"github.com/gkoh/bom_exporter/bom/connection/ftp"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	log "github.com/sirupsen/logrus"
+	"os"
+	"slices"
+	"strings"
+)
+
+// idList is a repeatable, comma separated list of product identifiers.
+// Repeated identifiers are only listed once.
+type idList []string
+
+func (l *idList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *idList) Set(v string) error {
+	for _, id := range strings.Split(v, ",") {
+		if id != "" && !slices.Contains(*l, id) {
+			*l = append(*l, id)
+		}
+	}
+	return nil
+}
+
+// withoutTimestamps strips sample timestamps, which node_exporter's textfile
+// collector rejects.
+func withoutTimestamps(g prometheus.Gatherer) prometheus.Gatherer {
+	return prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		mfs, err := g.Gather()
+		for _, mf := range mfs {
+			for _, m := range mf.Metric {
+				m.TimestampMs = nil
+			}
+		}
+		return mfs, err
+	})
+}
+
+// fetch retrieves and parses each product once, as selected by the metric
+// flags, and writes the combined metrics to output, or stdout if output is
+// "-". Products that fail are skipped and reported in the returned error. If
+// every product fails, output is left untouched so the textfile collector
+// keeps the previous metrics.
+func fetch(ids []string, newRetriever func(id string) connection.Retriever, output string) error {
+	registry := prometheus.NewPedanticRegistry()
+
+	var failed []string
+	for _, id := range ids {
+		m := newMetric(newRetriever(id))
+		err := m.RetrieveAndParse()
+		if err == nil {
+			err = registry.Register(m)
+		}
+		if err != nil {
+			log.Errorf("Failed to fetch '%s': %s", id, err)
+			failed = append(failed, id)
+		}
+	}
+
+	if len(failed) == len(ids) {
+		return fmt.Errorf("Failed to fetch %s, '%s' not written", strings.Join(failed, ", "), output)
+	}
+
+	g := withoutTimestamps(registry)
+
+	var err error
+	if output == "-" {
+		var mfs []*dto.MetricFamily
+		mfs, err = g.Gather()
+		enc := expfmt.NewEncoder(os.Stdout, expfmt.NewFormat(expfmt.TypeTextPlain))
+		for _, mf := range mfs {
+			if err == nil {
+				err = enc.Encode(mf)
+			}
+		}
+	} else {
+		err = prometheus.WriteToTextfile(output, g)
+	}
+	if err != nil {
+		return fmt.Errorf("Failed to write '%s': %w", output, err)
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("Failed to fetch %s", strings.Join(failed, ", "))
+	}
+
+	return nil
+}
+
+// runFetch implements the fetch subcommand, returning the process exit code.
+func runFetch(args []string) int {
+	fs := flag.NewFlagSet("fetch", flag.ExitOnError)
+	var ids idList
+	fs.Var(&ids, "id", "Product identifier to fetch, may be repeated or comma separated.")
+	output := fs.String("output", "-", "File to atomically write metrics to, '-' for stdout.")
+	address := fs.String("ftp.address", ftp.DefaultAddress, "host:port of the BoM FTP server.")
+	addMetricFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s fetch --id ID [--id ID...] [--output FILE]\n\n", os.Args[0])
+		fmt.Fprintf(fs.Output(), "Retrieve products once and write them in the text exposition format,\n")
+		fmt.Fprintf(fs.Output(), "suitable for node_exporter's textfile collector.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if len(ids) == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	err := fetch(ids, func(id string) connection.Retriever {
+		return ftp.NewWithAddress(*address, id)
+	}, *output)
+	if err != nil {
+		log.Error(err)
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"flag"
+	"github.com/gkoh/bom_exporter/bom/connection"
+	"github.com/gkoh/bom_exporter/bom/connection/file"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func fixtureRetriever(id string) connection.Retriever {
+	return file.New(filepath.Join("../bom/schema", id+".xml"))
+}
+
+func TestFetch(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "bom.prom")
+
+	err := fetch([]string{"IDS60920", "IDS10034"}, fixtureRetriever, output)
+	if err != nil {
+		t.Fatalf("Fetch failed: %s", err)
+	}
+
+	f, err := os.Open(output)
+	if err != nil {
+		t.Fatalf("Failed to open '%s': %s", output, err)
+	}
+	defer f.Close()
+
+	parser := expfmt.NewTextParser(model.UTF8Validation)
+	mfs, err := parser.TextToMetricFamilies(f)
+	if err != nil {
+		t.Fatalf("Failed to parse '%s': %s", output, err)
+	}
+
+	for _, name := range []string{"bom_observations_temperature", "bom_forecast_precis"} {
+		if _, ok := mfs[name]; !ok {
+			t.Errorf("Missing metric '%s'", name)
+		}
+	}
+
+	for name, mf := range mfs {
+		for _, m := range mf.Metric {
+			if m.TimestampMs != nil {
+				t.Fatalf("Metric '%s' has a timestamp", name)
+			}
+		}
+	}
+}
+
+func TestFetchFailure(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "bom.prom")
+
+	err := fetch([]string{"IDS60920", "IDX00000"}, fixtureRetriever, output)
+	if err == nil {
+		t.Errorf("Expected an error for a missing product")
+	}
+
+	// The successful product is still written.
+	data, err := os.ReadFile(output)
+	if err != nil || len(data) == 0 {
+		t.Errorf("Expected '%s' to be written: %v", output, err)
+	}
+}
+
+func TestFetchAllFailed(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "bom.prom")
+	previous := []byte("# previous metrics\n")
+
+	err := os.WriteFile(output, previous, 0644)
+	if err != nil {
+		t.Fatalf("Failed to write file: %s", err)
+	}
+
+	err = fetch([]string{"IDX00000", "IDX00001"}, fixtureRetriever, output)
+	if err == nil {
+		t.Errorf("Expected an error when every product fails")
+	}
+
+	// The previous metrics are left in place.
+	data, err := os.ReadFile(output)
+	if err != nil || string(data) != string(previous) {
+		t.Errorf("Expected '%s' to be unchanged, got '%s': %v", output, data, err)
+	}
+}
+
+func TestFetchDuplicate(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "bom.prom")
+
+	// The second collector fails to register, rather than panicking.
+	err := fetch([]string{"IDS60920", "IDS60920"}, fixtureRetriever, output)
+	if err == nil {
+		t.Errorf("Expected an error for a duplicate product")
+	}
+}
+
+func TestFetchMetricFlags(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "bom.prom")
+
+	fs := flag.NewFlagSet("fetch", flag.ContinueOnError)
+	addMetricFlags(fs)
+	err := fs.Parse([]string{"--metrics.base-units"})
+	if err != nil {
+		t.Fatalf("Failed to parse flags: %s", err)
+	}
+	defer func() { *baseUnits = false }()
+
+	err = fetch([]string{"IDS60920"}, fixtureRetriever, output)
+	if err != nil {
+		t.Fatalf("Fetch failed: %s", err)
+	}
+
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("Failed to read '%s': %s", output, err)
+	}
+	if !strings.Contains(string(data), "bom_observations_temperature_celsius") {
+		t.Errorf("Expected base unit metrics in '%s'", output)
+	}
+}
+
+func TestIDList(t *testing.T) {
+	var ids idList
+	ids.Set("IDS60920,IDN60920")
+	ids.Set("IDV60920")
+	ids.Set("IDS60920,IDV60920")
+
+	if len(ids) != 3 || ids.String() != "IDS60920,IDN60920,IDV60920" {
+		t.Errorf("Unexpected ids: %v", ids)
+	}
+}
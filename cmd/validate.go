@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/gkoh/bom_exporter/bom/validate"
+	"io"
+	"os"
+)
+
+// validateFiles reports on each file, returning the number that failed.
+func validateFiles(w io.Writer, files []string) int {
+	failed := 0
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			fmt.Fprintf(w, "%s: FAIL\n  error: %s\n", f, err)
+			failed++
+			continue
+		}
+
+		r := validate.Validate(data)
+		status := "OK"
+		if r.Failed() {
+			status = "FAIL"
+			failed++
+		}
+		fmt.Fprintf(w, "%s: %s\n", f, status)
+		r.Write(w)
+	}
+
+	fmt.Fprintf(w, "\n%d files, %d ok, %d failed\n", len(files), len(files)-failed, failed)
+	return failed
+}
+
+// runValidate implements the validate subcommand, returning the process exit
+// code.
+func runValidate(args []string) int {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s validate FILE...\n\n", os.Args[0])
+		fmt.Fprintf(fs.Output(), "Decode product XML files and report anything the collectors do not export.\n")
+	}
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	if validateFiles(os.Stdout, fs.Args()) > 0 {
+		return 1
+	}
+
+	return 0
+}
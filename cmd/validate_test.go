@@ -0,0 +1,28 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateFiles(t *testing.T) {
+	bad := filepath.Join(t.TempDir(), "bad.xml")
+	err := os.WriteFile(bad, []byte(`<product><amoc><issue-time-utc>yesterday</issue-time-utc></amoc></product>`), 0644)
+	if err != nil {
+		t.Fatalf("Failed to write file: %s", err)
+	}
+
+	var b bytes.Buffer
+
+	failed := validateFiles(&b, []string{"../bom/schema/IDS10034.xml", bad, "missing.xml"})
+	if failed != 2 {
+		t.Errorf("Got %d failed, expected 2:\n%s", failed, b.String())
+	}
+
+	if !strings.Contains(b.String(), "3 files, 1 ok, 2 failed") {
+		t.Errorf("Unexpected summary:\n%s", b.String())
+	}
+}